@@ -1,11 +1,18 @@
 package libx
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
+	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
 	"github.com/varunamachi/libx/httpx"
 )
@@ -19,10 +26,22 @@ type BuildInfo struct {
 	BuildUser string `json:"buildUser"`
 }
 
+// ShutdownHook - function called during application shutdown, the context
+// expires when the shutdown deadline is reached
+type ShutdownHook func(gtx context.Context) error
+
+type namedHook struct {
+	name string
+	hook ShutdownHook
+}
+
 type App struct {
 	*cli.App
-	server    *httpx.Server
-	buildInfo *BuildInfo
+	server          *httpx.Server
+	buildInfo       *BuildInfo
+	hooks           []namedHook
+	shutdownTimeout time.Duration
+	hookMutex       sync.Mutex
 }
 
 func NewApp(name, description, versionStr, author string) *App {
@@ -112,6 +131,80 @@ func (app *App) Serve(port uint32) error {
 	return app.server.Start(port)
 }
 
+// WithShutdownTimeout - max time given to in-flight requests to drain and
+// then separately to the shutdown hooks to finish
+func (app *App) WithShutdownTimeout(timeout time.Duration) *App {
+	app.shutdownTimeout = timeout
+	return app
+}
+
+// OnShutdown - registers a hook that is run during graceful shutdown after
+// the HTTP server has drained. Hooks are run in reverse order of registration
+// so that resources are released in the opposite order of acquisition
+func (app *App) OnShutdown(name string, hook ShutdownHook) *App {
+	app.hookMutex.Lock()
+	defer app.hookMutex.Unlock()
+	app.hooks = append(app.hooks, namedHook{name: name, hook: hook})
+	return app
+}
+
+// ServeContext - starts the server and blocks until the context is done or
+// the server fails, the application is gracefully shutdown in both cases
+func (app *App) ServeContext(gtx context.Context, port uint32) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- app.server.Start(port)
+	}()
+
+	var serveErr error
+	select {
+	case serveErr = <-errs:
+		if errors.Is(serveErr, http.ErrServerClosed) {
+			serveErr = nil
+		}
+	case <-gtx.Done():
+	}
+
+	if err := app.Shutdown(); err != nil {
+		return errors.Join(serveErr, err)
+	}
+	return serveErr
+}
+
+// Shutdown - performs ordered shutdown: server is marked not ready and stops
+// accepting connections, in-flight requests are drained until the deadline
+// and then the shutdown hooks are run in reverse registration order
+func (app *App) Shutdown() error {
+	timeout := data.NonEmpty(app.shutdownTimeout, httpx.DefaultShutdownTimeout)
+	errs := make([]error, 0, len(app.hooks)+1)
+
+	if app.server != nil {
+		log.Info().Dur("timeout", timeout).Msg("draining http requests")
+		dtx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := app.server.Shutdown(dtx); err != nil {
+			errs = append(errs, err)
+		}
+		cancel()
+	}
+
+	app.hookMutex.Lock()
+	hooks := slices.Clone(app.hooks)
+	app.hookMutex.Unlock()
+
+	htx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		log.Info().Str("hook", hooks[i].name).Msg("running shutdown hook")
+		if err := hooks[i].hook(htx); err != nil {
+			log.Error().Err(err).Str("hook", hooks[i].name).
+				Msg("shutdown hook failed")
+			errs = append(errs,
+				errx.Errf(err, "shutdown hook '%s' failed", hooks[i].name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (app *App) StopServer() error {
 	if app.server == nil {
 		log.Trace().Msg("no running server found to stop")
@@ -2,7 +2,7 @@ package auth
 
 import (
 	"context"
-	"errors"
+	"net/http"
 	"os"
 	"time"
 
@@ -43,10 +43,19 @@ func (ad AuthData) Decode(out any) error {
 // }
 
 var (
-	ErrAuthentication         = errors.New("auth.user.authenticationError")
-	ErrUserRetrieval          = errors.New("auth.user.retrievalError")
-	ErrToken                  = errors.New("auth.user.authTokenError")
-	ErrInsufficientPrivileges = errors.New("auth.user.insufficient.privs")
+	ErrAuthentication = errx.Declare(
+		"auth.user.authenticationError", http.StatusUnauthorized,
+		"Authentication Failed", "failed to authenticate the user")
+	ErrUserRetrieval = errx.Declare(
+		"auth.user.retrievalError", http.StatusInternalServerError,
+		"User Retrieval Failed", "failed to retrieve user information")
+	ErrToken = errx.Declare(
+		"auth.user.authTokenError", http.StatusUnauthorized,
+		"Invalid Token", "authentication token is invalid or expired")
+	ErrInsufficientPrivileges = errx.Declare(
+		"auth.user.insufficient.privs", http.StatusForbidden,
+		"Insufficient Privileges",
+		"user does not have privileges required for this operation")
 )
 
 type Authenticator interface {
@@ -28,7 +28,9 @@ func GetDataForRandomFilter(
 		SortDescending: false,
 	}
 
-	if err = pg.NewGetterDeleter().Get(gtx, dataType, &cp, out); err != nil {
+	gd := pg.NewGetterDeleterWithSpecs(
+		map[string][]*data.FilterSpec{dataType: UserFilterSpec})
+	if err = gd.Get(gtx, dataType, &cp, out); err != nil {
 		return errx.Errf(
 			err,
 			"failed to get random values from table '%s'",
@@ -7,26 +7,41 @@ import (
 )
 
 type FkUser struct {
-	Id        int              `db:"id" fake:"skip"`
-	Name      string           `db:"name" fake:"{name}"`
-	FirstName string           `db:"first_name" fake:"{firstname}"`
-	LastName  string           `db:"last_name" fake:"{lastname}"`
-	Email     string           `db:"email" fake:"{email}"`
-	Age       int              `db:"age" fake:"{number:1,100}"`
-	Tags      data.Vec[string] `db:"tags" fakesize:"2"`
-	Status    string           `db:"status" fake:"{randomstring:[active,inactive]}"`
+	_         struct{}         `db:"fake_user"`
+	Id        int              `db:"id,key,auto" pg:"type=INT" fake:"skip"`
+	Name      string           `db:"name" pg:"type=VARCHAR(60)" fake:"{name}"`
+	FirstName string           `db:"first_name" pg:"type=VARCHAR(60)" fake:"{firstname}"`
+	LastName  string           `db:"last_name" pg:"type=VARCHAR(60)" fake:"{lastname}"`
+	Email     string           `db:"email" pg:"type=VARCHAR(60)" fake:"{email}"`
+	Age       int              `db:"age" pg:"type=INT" fake:"{number:1,100}"`
+	Tags      data.Vec[string] `db:"tags" pg:"type=VARCHAR[],default='{}'" fakesize:"2"`
+	Status    string           `db:"status" pg:"type=VARCHAR(20),null,default='inactive'" fake:"{randomstring:[active,inactive]}"`
 	Created   time.Time        `db:"created"`
 	Updated   time.Time        `db:"updated"`
 }
 
 type FkItem struct {
-	Id          int       `db:"id" fake:"skip"`
-	Name        string    `db:"name" fake:"{name}"`
-	Description string    `db:"description" fake:"{sentence:3}"`
+	_           struct{}  `db:"fake_item"`
+	Id          int       `db:"id,key,auto" pg:"type=INT" fake:"skip"`
+	Name        string    `db:"name" pg:"type=VARCHAR(60)" fake:"{name}"`
+	Description string    `db:"description" pg:"type=VARCHAR(120)" fake:"{sentence:3}"`
 	Created     time.Time `db:"created"`
 	Updated     time.Time `db:"updated"`
 }
 
+// Models - storage description of the fake data models
+func Models() []*data.ModelInfo {
+	user, err := data.NewModelInfo[FkUser]("db")
+	if err != nil {
+		panic(err)
+	}
+	item, err := data.NewModelInfo[FkItem]("db")
+	if err != nil {
+		panic(err)
+	}
+	return []*data.ModelInfo{user, item}
+}
+
 var UserFilterSpec = []*data.FilterSpec{
 	{Field: "name", Name: "Name", Type: data.FtProp},
 	// {Field: "first_name", Name: "First Name", Type: data.FtProp},
@@ -6,6 +6,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/varunamachi/libx"
 	"github.com/varunamachi/libx/cmd/tu/fnm"
+	"github.com/varunamachi/libx/data/pg"
 	"github.com/varunamachi/libx/errx"
 	"github.com/varunamachi/libx/rt"
 )
@@ -16,6 +17,8 @@ func main() {
 
 	app := libx.NewApp("xone", "test app", "0.0.1", "me")
 	app.Commands = append(app.Commands, fnm.Commands()...)
+	app.Commands = append(app.Commands, pg.JobCommands())
+	app.Commands = append(app.Commands, pg.SchemaCommands(fnm.Models()...))
 
 	if err := app.RunContext(gtx, os.Args); err != nil {
 		errx.PrintSomeStack(err)
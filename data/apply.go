@@ -0,0 +1,486 @@
+package data
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidFilter = errx.Declare(
+		"data.filter.invalid", http.StatusBadRequest,
+		"Invalid Filter", "filter is invalid")
+)
+
+// Apply - evaluates the filter on the items in memory with the semantics of
+// the database backends, then sorts and pages the matching items as given by
+// params. If filter is nil, the filter in params is used. Fields are resolved
+// using 'json' and 'db' tags through ToFlatMap, nested fields are given by
+// dotted paths and elements of slices are matched individually. Like SQL
+// NULL, missing and nil fields do not match any condition. Search patterns
+// follow SQL's SIMILAR TO. Cursor in params is not supported, ErrInvalidCursor
+// is returned if one is given
+func Apply[T any](
+	items []T, filter *Filter, params *CommonParams) ([]T, error) {
+	recs := make([]*record, 0, len(items))
+	for idx := range items {
+		recs = append(recs, newRecord(idx, &items[idx]))
+	}
+	recs, err := apply(recs, filter, params)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]T, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, items[rec.index])
+	}
+	return out, nil
+}
+
+// record - flattened item, values of slice elements are also collected under
+// the path without indices
+type record struct {
+	index int
+	flat  map[string]any
+	lists map[string][]any
+}
+
+var indexPartRx = regexp.MustCompile(`\.\d+(\.|$)`)
+
+func newRecord(index int, item any) *record {
+	// Walk needs addressable values to visit the fields
+	val := reflect.ValueOf(item)
+	if val.IsValid() && val.Kind() != reflect.Ptr {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		item = ptr.Interface()
+	}
+
+	rec := &record{
+		index: index,
+		flat:  ToFlatMap(item, "db"),
+		lists: map[string][]any{},
+	}
+	for key, val := range ToFlatMap(item, "json") {
+		rec.flat[key] = val
+	}
+
+	keys := make([]string, 0, len(rec.flat))
+	for key := range rec.flat {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, compareIndexedPaths)
+	for _, key := range keys {
+		if base := indexPartRx.ReplaceAllString(key, "$1"); base != key {
+			rec.lists[base] = append(rec.lists[base], rec.flat[key])
+		}
+	}
+	return rec
+}
+
+// compareIndexedPaths - orders paths so that slice indices are in numeric
+// order
+func compareIndexedPaths(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for idx := 0; idx < len(as) && idx < len(bs); idx++ {
+		ai, aerr := strconv.Atoi(as[idx])
+		bi, berr := strconv.Atoi(bs[idx])
+		if aerr == nil && berr == nil {
+			if ai != bi {
+				return cmp.Compare(ai, bi)
+			}
+			continue
+		}
+		if c := strings.Compare(as[idx], bs[idx]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// values - value of the field, or elements if it is a slice
+func (rec *record) values(field string) []any {
+	if val, found := rec.flat[field]; found {
+		if val = normalize(val); val != nil {
+			return []any{val}
+		}
+		return nil
+	}
+	vals := make([]any, 0, len(rec.lists[field]))
+	for _, val := range rec.lists[field] {
+		if val = normalize(val); val != nil {
+			vals = append(vals, val)
+		}
+	}
+	return vals
+}
+
+// value - value of the field, first element if it is a slice
+func (rec *record) value(field string) (any, bool) {
+	vals := rec.values(field)
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return vals[0], true
+}
+
+func apply(
+	recs []*record, filter *Filter, params *CommonParams) ([]*record, error) {
+	if params != nil && params.Cursor != "" {
+		return nil, errx.Errf(ErrInvalidCursor,
+			"cursors are not supported for items in memory")
+	}
+	if filter == nil && params != nil {
+		filter = params.Filter
+	}
+	pred, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*record, 0, len(recs))
+	for _, rec := range recs {
+		if pred(rec) {
+			out = append(out, rec)
+		}
+	}
+	if params == nil {
+		return out, nil
+	}
+
+	sorts := params.SortFields()
+	for _, sf := range sorts {
+		if err := sf.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(sorts) != 0 {
+		slices.SortStableFunc(out, func(a, b *record) int {
+			return compareRecords(a, b, sorts)
+		})
+	}
+
+	if params.Limit() <= 0 {
+		return out, nil
+	}
+	// Negative pages are taken as the first page
+	start := min(max(params.Offset(), 0), int64(len(out)))
+	end := min(start+params.Limit(), int64(len(out)))
+	return out[start:end], nil
+}
+
+// compareRecords - nulls are larger than other values as in postgres unless
+// the sort field says otherwise
+func compareRecords(a, b *record, sorts []SortField) int {
+	for _, sf := range sorts {
+		av, aok := a.value(sf.Field)
+		bv, bok := b.value(sf.Field)
+		if !aok || !bok {
+			if aok == bok {
+				continue
+			}
+			nullsFirst := sf.Nulls == NullsFirst ||
+				(sf.Nulls == NullsDefault && sf.Desc)
+			return Qop(!aok == nullsFirst, -1, 1)
+		}
+		c := compareValues(av, bv)
+		if sf.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+type predicate func(rec *record) bool
+
+func compileFilter(filter *Filter) (predicate, error) {
+	if filter == nil {
+		return func(*record) bool { return true }, nil
+	}
+
+	preds := make([]predicate, 0, 10)
+	addIn := func(matchers map[string]*Matcher) {
+		for field, matcher := range matchers {
+			if matcher == nil || len(matcher.Fields) == 0 {
+				continue
+			}
+			preds = append(preds, inPredicate(field, matcher))
+		}
+	}
+	addIn(filter.Props)
+	addIn(filter.Lists)
+	addIn(filter.Constants)
+
+	for field, val := range filter.Bools {
+		if val == nil {
+			continue
+		}
+		preds = append(preds, func(rec *record) bool {
+			rv, found := rec.value(field)
+			return found && equalValues(rv, normalize(val))
+		})
+	}
+
+	for field, dr := range filter.Dates {
+		if dr == nil || !dr.IsValid() {
+			continue
+		}
+		preds = append(preds,
+			betweenPredicate(field, dr.From, dr.To, dr.Invert))
+	}
+	for field, rg := range filter.Ranges {
+		if rg == nil || !rg.IsValid() {
+			continue
+		}
+		preds = append(preds,
+			betweenPredicate(field, rg.From, rg.To, rg.Invert))
+	}
+
+	for field, matcher := range filter.Searches {
+		if matcher == nil || len(matcher.Fields) == 0 {
+			continue
+		}
+		pred, err := searchPredicate(field, matcher)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+
+	return func(rec *record) bool {
+		for _, pred := range preds {
+			if !pred(rec) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func inPredicate(field string, matcher *Matcher) predicate {
+	wanted := make([]any, 0, len(matcher.Fields))
+	for _, val := range matcher.Fields {
+		wanted = append(wanted, normalize(val))
+	}
+	has := func(vals []any, want any) bool {
+		return slices.ContainsFunc(vals, func(val any) bool {
+			return equalValues(val, want)
+		})
+	}
+	return func(rec *record) bool {
+		vals := rec.values(field)
+		if len(vals) == 0 {
+			return false
+		}
+		if matcher.All {
+			for _, want := range wanted {
+				if !has(vals, want) {
+					return matcher.Invert
+				}
+			}
+			return !matcher.Invert
+		}
+		for _, want := range wanted {
+			if has(vals, want) {
+				return !matcher.Invert
+			}
+		}
+		return matcher.Invert
+	}
+}
+
+func betweenPredicate(field string, from, to any, invert bool) predicate {
+	from, to = normalize(from), normalize(to)
+	return func(rec *record) bool {
+		val, found := rec.value(field)
+		if !found {
+			return false
+		}
+		lo, lok := compare(val, from)
+		hi, hok := compare(val, to)
+		if !lok || !hok {
+			return false
+		}
+		return (lo >= 0 && hi <= 0) != invert
+	}
+}
+
+func searchPredicate(field string, matcher *Matcher) (predicate, error) {
+	rxs := make([]*regexp.Regexp, 0, len(matcher.Fields))
+	for _, pattern := range matcher.Fields {
+		str, ok := pattern.(string)
+		if !ok {
+			return nil, errx.Errf(ErrInvalidFilter,
+				"search pattern for '%s' is not a string", field)
+		}
+		rx, err := SimilarToRegexp(str)
+		if err != nil {
+			return nil, errx.Errf(ErrInvalidFilter,
+				"invalid search pattern '%s' for '%s'", str, field)
+		}
+		rxs = append(rxs, rx)
+	}
+
+	return func(rec *record) bool {
+		vals := rec.values(field)
+		if len(vals) == 0 {
+			return false
+		}
+		for _, val := range vals {
+			str := fmt.Sprint(val)
+			for _, rx := range rxs {
+				if rx.MatchString(str) {
+					return !matcher.Invert
+				}
+			}
+		}
+		return matcher.Invert
+	}, nil
+}
+
+// SimilarToRegexp - converts a pattern for SQL's SIMILAR TO operator into a
+// regular expression matching whole string. '%' and '_' match any string
+// and any character, '\' escapes the next character
+func SimilarToRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^(?s:")
+	runes := []rune(pattern)
+	for idx := 0; idx < len(runes); idx++ {
+		switch r := runes[idx]; r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		case '\\':
+			if idx+1 < len(runes) {
+				idx++
+				sb.WriteString(regexp.QuoteMeta(string(runes[idx])))
+			}
+		case '.', '^', '$':
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(")$")
+	return regexp.Compile(sb.String())
+}
+
+// normalize - converts numbers to float64, named basic types to the basic
+// type and dereferences pointers so that values can be compared
+func normalize(val any) any {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v
+	case json.Number:
+		if num, err := v.Float64(); err == nil {
+			return num
+		}
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	}
+	return val
+}
+
+// compare - compares normalized values. Strings are converted to numbers or
+// time when compared with one. Returns false if values are not comparable
+func compare(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := toFloat(b); ok {
+			return cmp.Compare(av, bv), true
+		}
+	case time.Time:
+		if bv, ok := toTime(b); ok {
+			return av.Compare(bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return cmp.Compare(Qop(av, 1, 0), Qop(bv, 1, 0)), true
+		}
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), true
+		case float64, time.Time:
+			c, ok := compare(b, a)
+			return -c, ok
+		}
+	}
+	return 0, false
+}
+
+// compareValues - compare that gives an order for values that are not
+// comparable, used for sorting
+func compareValues(a, b any) int {
+	if c, ok := compare(a, b); ok {
+		return c
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func equalValues(a, b any) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(val any) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case string:
+		num, err := strconv.ParseFloat(v, 64)
+		return num, err == nil && !math.IsNaN(num)
+	}
+	return 0, false
+}
+
+func toTime(val any) (time.Time, bool) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if tm, err := time.Parse(layout, v); err == nil {
+				return tm, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
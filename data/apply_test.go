@@ -0,0 +1,185 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyNegativePage(t *testing.T) {
+	type item struct {
+		Id int `json:"id"`
+	}
+	items := make([]item, 0, 25)
+	for i := 0; i < 25; i++ {
+		items = append(items, item{Id: i})
+	}
+
+	tests := []struct {
+		name     string
+		page     int64
+		pageSize int64
+		first    int
+		count    int
+	}{
+		{"negative page", -1, 10, 0, 10},
+		{"large negative page", -100, 10, 0, 10},
+		{"first page", 0, 10, 0, 10},
+		{"last page", 2, 10, 20, 5},
+		{"past the end", 5, 10, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Apply(items, nil, &CommonParams{
+				Page:     test.page,
+				PageSize: test.pageSize,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(out) != test.count {
+				t.Fatalf("expected %d items, got %d", test.count, len(out))
+			}
+			if test.count != 0 && out[0].Id != test.first {
+				t.Fatalf("expected first item %d, got %d",
+					test.first, out[0].Id)
+			}
+		})
+	}
+}
+
+type applyItem struct {
+	Id      int       `json:"id"`
+	Name    string    `json:"name"`
+	Tags    []string  `json:"tags"`
+	Age     *int      `json:"age"`
+	Created time.Time `json:"created"`
+	Active  bool      `json:"active"`
+	Owner   struct {
+		City string `json:"city"`
+	} `json:"owner"`
+}
+
+func applyItems() []applyItem {
+	age := func(val int) *int { return &val }
+	day := func(month, day int) time.Time {
+		return time.Date(2024, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+	items := []applyItem{
+		{Id: 1, Name: "ann", Tags: []string{"a", "b"}, Age: age(20),
+			Created: day(1, 10), Active: true},
+		{Id: 2, Name: "bob", Tags: []string{"b"}, Age: age(30),
+			Created: day(2, 1)},
+		{Id: 3, Name: "cid", Tags: []string{"c"},
+			Created: day(3, 5), Active: true},
+		{Id: 4, Name: "dan", Age: age(25), Created: day(1, 31)},
+	}
+	items[0].Owner.City = "pune"
+	items[1].Owner.City = "oslo"
+	return items
+}
+
+func TestApplyFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		ids   []int
+	}{
+		{"", []int{1, 2, 3, 4}},
+		{"name:ann,bob", []int{1, 2}},
+		{"-name:ann", []int{2, 3, 4}},
+		{"tags:[a,c]", []int{1, 3}},
+		{"tags:&[a,b]", []int{1}},
+		{"tags:&[b]", []int{1, 2}},
+		// Items without tags match neither the term nor the inverted one
+		{"-tags:[b]", []int{3}},
+		{"-tags:&[a,b]", []int{2, 3}},
+		{"owner.city:pune", []int{1}},
+		{"-owner.city:pune", []int{2, 3, 4}},
+		{"age:20..25", []int{1, 4}},
+		{"age>20", []int{2, 4}},
+		{"age<=25", []int{1, 4}},
+		{"age>=20 age<30", []int{1, 4}},
+		// Missing age matches neither the range nor the inverted range
+		{"-age:20..25", []int{2}},
+		{"-age:30", []int{1, 4}},
+		{"created:2024-01-01..2024-01-31", []int{1, 4}},
+		{"created>2024-01-31", []int{2, 3}},
+		{"created<2024-01-31", []int{1}},
+		{"-created:2024-02-01..2024-12-31", []int{1, 4}},
+		{"active:true", []int{1, 3}},
+		{"-active:true", []int{2, 4}},
+		{`name~"a%"`, []int{1}},
+		{`name~"_o_"`, []int{2}},
+		{`name~"%(n|d)"`, []int{1, 3, 4}},
+		{`name~"a%","b%"`, []int{1, 2}},
+		{`-name~"%n"`, []int{2, 3}},
+		{`name~"A%"`, nil},
+		{`name~"a.%"`, nil},
+		{"name:ann age>25", nil},
+		{"tags:[b] active:true", []int{1}},
+	}
+	items := applyItems()
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			filter, err := ParseFilter(test.query)
+			if err != nil {
+				t.Fatalf("failed to parse filter: %v", err)
+			}
+			out, err := Apply(items, filter, &CommonParams{Sort: "id"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkIds(t, out, test.ids)
+		})
+	}
+}
+
+func TestApplySort(t *testing.T) {
+	tests := []struct {
+		sort string
+		ids  []int
+	}{
+		{"name", []int{1, 2, 3, 4}},
+		{"-created", []int{3, 2, 4, 1}},
+		// Nulls are last in ascending and first in descending order
+		{"age", []int{1, 4, 2, 3}},
+		{"-age", []int{3, 2, 4, 1}},
+		{"age:nullsfirst", []int{3, 1, 4, 2}},
+		{"-age:nullslast", []int{2, 4, 1, 3}},
+		{"-active,id", []int{1, 3, 2, 4}},
+		{"owner.city,-id", []int{4, 3, 2, 1}},
+	}
+	items := applyItems()
+	for _, test := range tests {
+		t.Run(test.sort, func(t *testing.T) {
+			sorts, err := ParseSorts(test.sort)
+			if err != nil {
+				t.Fatalf("failed to parse sort: %v", err)
+			}
+			out, err := Apply(items, nil, &CommonParams{Sorts: sorts})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkIds(t, out, test.ids)
+		})
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	_, err := Apply(applyItems(), nil, &CommonParams{Cursor: "abc"})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func checkIds(t *testing.T, items []applyItem, ids []int) {
+	t.Helper()
+	got := make([]int, 0, len(items))
+	for _, item := range items {
+		got = append(got, item.Id)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("expected items %v, got %v", ids, got)
+	}
+}
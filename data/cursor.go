@@ -0,0 +1,186 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidCursor = errx.Declare(
+		"data.cursor.invalid", http.StatusBadRequest,
+		"Invalid Cursor", "pagination cursor is invalid or does not match "+
+			"the sort order")
+)
+
+// Page - a page of items with opaque cursors to get the adjacent pages. A
+// cursor is empty if there is no page in that direction
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// cursor - content of the opaque cursor token
+type cursor struct {
+	Fields   []string          `json:"f"`
+	Desc     []bool            `json:"d"`
+	Values   []json.RawMessage `json:"v"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+// Keyset - sort fields with key field as the tie breaker and the position
+// decoded from the cursor. Values is nil when there is no cursor
+type Keyset struct {
+	Fields   []string
+	Desc     []bool
+	Values   []any
+	Backward bool
+}
+
+// NewKeyset - creates keyset for the model from sort fields and cursor in the
+// params. Unless already sorted by it, key field is added as the last sort
+// field in the direction of the previous field so that the order is stable.
+// Cursor values are decoded into the type of respective fields. Nulls order
+// is not supported since null values can not be compared
+func NewKeyset(mi *ModelInfo, params *CommonParams) (*Keyset, error) {
+	ks := &Keyset{}
+	keyDesc := params.SortDescending
+	for _, sf := range params.SortFields() {
+		if err := sf.Validate(); err != nil {
+			return nil, err
+		}
+		if err := mi.CheckFields(sf.Field); err != nil {
+			return nil, err
+		}
+		if sf.Nulls != NullsDefault {
+			return nil, errx.Errf(ErrInvalidSort,
+				"nulls order for '%s' is not supported with keyset "+
+					"pagination", sf.Field)
+		}
+		if slices.Contains(ks.Fields, sf.Field) {
+			return nil, errx.Errf(ErrInvalidSort,
+				"field '%s' is sorted more than once", sf.Field)
+		}
+		ks.Fields = append(ks.Fields, sf.Field)
+		ks.Desc = append(ks.Desc, sf.Desc)
+		keyDesc = sf.Desc
+		if sf.Field == mi.Key.Name {
+			break
+		}
+	}
+	if !slices.Contains(ks.Fields, mi.Key.Name) {
+		ks.Fields = append(ks.Fields, mi.Key.Name)
+		ks.Desc = append(ks.Desc, keyDesc)
+	}
+
+	if params.Cursor == "" {
+		return ks, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+	if err != nil {
+		return nil, errx.Errf(ErrInvalidCursor, "cursor is not valid base64")
+	}
+	var cur cursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, errx.Errf(ErrInvalidCursor, "malformed cursor")
+	}
+	if !slices.Equal(cur.Fields, ks.Fields) ||
+		!slices.Equal(cur.Desc, ks.Desc) ||
+		len(cur.Values) != len(ks.Fields) {
+		return nil, errx.Errf(ErrInvalidCursor,
+			"cursor was created for a different sort order")
+	}
+
+	ks.Values = make([]any, len(cur.Values))
+	for idx, rawVal := range cur.Values {
+		ftype := mi.rtype.FieldByIndex(mi.byName[ks.Fields[idx]].index).Type
+		ptr := reflect.New(ftype)
+		if err := json.Unmarshal(rawVal, ptr.Interface()); err != nil {
+			return nil, errx.Errf(ErrInvalidCursor,
+				"invalid cursor value for '%s'", ks.Fields[idx])
+		}
+		ks.Values[idx] = ptr.Elem().Interface()
+	}
+	ks.Backward = cur.Backward
+	return ks, nil
+}
+
+// QueryDesc - direction of each field for the query, which is reversed when
+// moving backward
+func (ks *Keyset) QueryDesc() []bool {
+	out := make([]bool, len(ks.Desc))
+	for idx, desc := range ks.Desc {
+		out[idx] = desc != ks.Backward
+	}
+	return out
+}
+
+func (ks *Keyset) encode(mi *ModelInfo, item any, backward bool) string {
+	cur := cursor{
+		Fields:   ks.Fields,
+		Desc:     ks.Desc,
+		Values:   make([]json.RawMessage, 0, len(ks.Fields)),
+		Backward: backward,
+	}
+	for _, field := range ks.Fields {
+		val := mi.field(item, mi.byName[field]).Interface()
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		cur.Values = append(cur.Values, raw)
+	}
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// NewPage - creates a page from items fetched using the keyset. Backends
+// fetch one item more than the limit so that presence of further items is
+// known. Items fetched when moving backward are reversed here. With limit 0,
+// all the items are returned without cursors
+func NewPage[T any](
+	mi *ModelInfo,
+	ks *Keyset,
+	params *CommonParams,
+	items []T) *Page[T] {
+	limit := int(params.Limit())
+	if limit <= 0 {
+		return &Page[T]{Items: items}
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	if ks.Backward {
+		slices.Reverse(items)
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		return page
+	}
+
+	first, last := &items[0], &items[len(items)-1]
+	startedMidway := ks.Values != nil || params.Page > 0
+	if ks.Backward {
+		page.NextCursor = ks.encode(mi, last, false)
+		if hasMore {
+			page.PrevCursor = ks.encode(mi, first, true)
+		}
+	} else {
+		if hasMore {
+			page.NextCursor = ks.encode(mi, last, false)
+		}
+		if startedMidway {
+			page.PrevCursor = ks.encode(mi, first, true)
+		}
+	}
+	return page
+}
@@ -0,0 +1,108 @@
+package data
+
+import "time"
+
+// DefaultBuckets - number of histogram buckets for range facets if the
+// filter spec does not give one
+const DefaultBuckets = 10
+
+// FacetValue - a value of a field along with number of items having it
+type FacetValue struct {
+	Value any   `json:"value" db:"value" bson:"value"`
+	Count int64 `json:"count" db:"count" bson:"count"`
+}
+
+// Bucket - histogram bucket of a range field. From and To are float64 for
+// number ranges and time.Time for date ranges
+type Bucket struct {
+	From  any   `json:"from" db:"from" bson:"from"`
+	To    any   `json:"to" db:"to" bson:"to"`
+	Count int64 `json:"count" db:"count" bson:"count"`
+}
+
+// Facet - counts for a filter field. Values are given for Prop and Array
+// fields in decreasing order of count, items with values beyond the top N
+// are counted in Other. Buckets are given for range fields
+type Facet struct {
+	Values  []FacetValue `json:"values,omitempty" db:"values" bson:"values,omitempty"`
+	Other   int64        `json:"other,omitempty" db:"other" bson:"other,omitempty"`
+	Buckets []Bucket     `json:"buckets,omitempty" db:"buckets" bson:"buckets,omitempty"`
+}
+
+// NumBuckets - number of histogram buckets to use for the spec
+func (fs *FilterSpec) NumBuckets() int {
+	if fs.Buckets > 0 {
+		return fs.Buckets
+	}
+	return DefaultBuckets
+}
+
+// NewBuckets - equal width buckets between lo and hi with the given counts.
+// Bucket boundaries are converted to field's values using conv, say
+// FromEpoch for dates
+func NewBuckets(
+	lo, hi float64, counts []int64, conv func(float64) any) []Bucket {
+	num := len(counts)
+	width := (hi - lo) / float64(num)
+	buckets := make([]Bucket, 0, num)
+	for idx, count := range counts {
+		to := lo + width*float64(idx+1)
+		if idx == num-1 {
+			to = hi
+		}
+		buckets = append(buckets, Bucket{
+			From:  conv(lo + width*float64(idx)),
+			To:    conv(to),
+			Count: count,
+		})
+	}
+	return buckets
+}
+
+// AsNumber - converts bucket boundary to a number, to be used with
+// NewBuckets
+func AsNumber(val float64) any {
+	return val
+}
+
+// FromEpoch - converts bucket boundary given in seconds since epoch to time,
+// to be used with NewBuckets
+func FromEpoch(secs float64) any {
+	return EpochTime(secs)
+}
+
+// EpochTime - UTC time from fractional seconds since epoch
+func EpochTime(secs float64) time.Time {
+	return time.UnixMicro(int64(secs * 1e6)).UTC()
+}
+
+// Without - copy of the filter without the conditions on given field. Used
+// to compute facet of a field using the filter excluding field's own
+// condition. Matchers are shared with the original filter
+func (f *Filter) Without(field string) *Filter {
+	if f == nil {
+		return nil
+	}
+	return &Filter{
+		Bools:     without(f.Bools, field),
+		Props:     without(f.Props, field),
+		Lists:     without(f.Lists, field),
+		Searches:  without(f.Searches, field),
+		Constants: without(f.Constants, field),
+		Dates:     without(f.Dates, field),
+		Ranges:    without(f.Ranges, field),
+	}
+}
+
+func without[V any](mp map[string]V, field string) map[string]V {
+	if mp == nil {
+		return nil
+	}
+	out := make(map[string]V, len(mp))
+	for key, val := range mp {
+		if key != field {
+			out[key] = val
+		}
+	}
+	return out
+}
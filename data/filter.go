@@ -57,6 +57,20 @@ func (f *Filter) ListNotIn(key string, values ...any) *Filter {
 	return f.matcher("list", f.Lists, true, key, values...)
 }
 
+// ListHasAll - list has all the given values
+func (f *Filter) ListHasAll(key string, values ...any) *Filter {
+	f.matcher("list", f.Lists, false, key, values...)
+	f.Lists[key].All = true
+	return f
+}
+
+// ListNotHasAll - list does not have all the given values
+func (f *Filter) ListNotHasAll(key string, values ...any) *Filter {
+	f.matcher("list", f.Lists, true, key, values...)
+	f.Lists[key].All = true
+	return f
+}
+
 func (f *Filter) SerachIn(key string, values ...any) *Filter {
 	return f.matcher("search", f.Searches, false, key, values...)
 }
@@ -84,6 +98,7 @@ func (f *Filter) RangeIn(key string, from, to float64) *Filter {
 	if !rng.IsValid() {
 		panic(fmt.Errorf("invalid range '%f => %f'", from, to))
 	}
+	f.Ranges[key] = &rng
 	return f
 }
 
@@ -98,6 +113,7 @@ func (f *Filter) RangeNotIn(key string, from, to float64) *Filter {
 	if !rng.IsValid() {
 		panic(fmt.Errorf("invalid range '%f => %f'", from, to))
 	}
+	f.Ranges[key] = &rng
 	return f
 }
 
@@ -112,6 +128,7 @@ func (f *Filter) DateRangeIn(key string, from, to time.Time) *Filter {
 	if !rng.IsValid() {
 		panic(fmt.Errorf("invalid range '%v => %v'", from, to))
 	}
+	f.Dates[key] = &rng
 	return f
 }
 
@@ -126,6 +143,7 @@ func (f *Filter) DateRangeNotIn(key string, from, to time.Time) *Filter {
 	if !rng.IsValid() {
 		panic(fmt.Errorf("invalid range '%v => %v'", from, to))
 	}
+	f.Dates[key] = &rng
 	return f
 }
 
@@ -135,9 +153,7 @@ func (f *Filter) matcher(
 	invert bool,
 	key string,
 	values ...any) *Filter {
-	// _, ok := value.(bool)
-	// Check value
-	if values != nil {
+	if len(values) == 0 {
 		panic(fmt.Errorf("invalid '%s' item '%s' given for filter", tp, key))
 	}
 	mp[key] = &Matcher{
@@ -183,10 +199,14 @@ func (f *Filter) IsValid(name string, val any) bool {
 
 }
 
+// FilterValues - values available for each filter field. Facets have the
+// counts for each value or histogram bucket of the field, computed using
+// the filter without the field's own condition
 type FilterValues struct {
 	Values map[string][]any        `json:"values" db:"values" bson:"values"`
 	Dates  map[string]*DateRange   `json:"dates" db:"dates" bson:"dates"`
 	Ranges map[string]*NumberRange `json:"ranges" db:"ranges" bson:"ranges"`
+	Facets map[string]*Facet       `json:"facets" db:"facets" bson:"facets"`
 }
 
 func NewFilterValues() *FilterValues {
@@ -194,5 +214,6 @@ func NewFilterValues() *FilterValues {
 		Values: make(map[string][]any),
 		Dates:  make(map[string]*DateRange),
 		Ranges: make(map[string]*NumberRange),
+		Facets: make(map[string]*Facet),
 	}
 }
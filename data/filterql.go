@@ -0,0 +1,722 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter query language, a compact text form of Filter. Terms are separated
+// by spaces and all of them must match:
+//
+//	status:active,pending     value is one of the given values (Props)
+//	tags:[spam,ads]           list has any of the given values (Lists)
+//	tags:&[spam,ads]          list has all of the given values
+//	age:20..40                value in the inclusive range (Ranges/Dates)
+//	age>=20  age<40           open ranges, also with '>' and '<='; a lower
+//	                          and an upper bound on a field give a range
+//	created>2024-01-01        dates are given as RFC 3339 or yyyy-mm-dd
+//	name~"jo%"                matches any of the SIMILAR TO patterns
+//	active:true               a single boolean value (Bools)
+//	-tags:[spam]              '-' inverts the term, for booleans it inverts
+//	                          the value i.e. -active:true is active:false
+//
+// Values with spaces or special characters are double quoted with '\'
+// escapes, quoted values are always strings. Unquoted values are taken as
+// numbers or booleans if they look like one. When filter specs are given,
+// fields must be one of the specs and the spec type decides the kind of
+// condition and the value types. Keys in a field with FtJson spec are given
+// as the field followed by the path, e.g. 'metadata.plan:pro', value types
+// of such keys are guessed like when there are no specs
+
+// FilterSyntaxError - error in a filter query, Pos is the byte offset of the
+// error in the query
+type FilterSyntaxError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (fse *FilterSyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d", fse.Msg, fse.Pos+1)
+}
+
+func (fse *FilterSyntaxError) Unwrap() error {
+	return ErrInvalidFilter
+}
+
+var (
+	// open ends of ranges given by comparison operators
+	minDate = time.Date(1, 1, 1, 0, 0, 1, 0, time.UTC)
+	maxDate = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	bareValueRx = regexp.MustCompile(`^[^\s,\[\]"\\]+$`)
+)
+
+// ParseFilter - parses a filter query, see the description of the filter
+// query language above. Errors are *FilterSyntaxError, which is also
+// ErrInvalidFilter
+func ParseFilter(query string, specs ...*FilterSpec) (*Filter, error) {
+	psr := &filterParser{
+		query:  query,
+		filter: NewFilter(),
+	}
+	if len(specs) != 0 {
+		psr.specs = make(map[string]*FilterSpec, len(specs))
+		for _, spec := range specs {
+			psr.specs[spec.Field] = spec
+		}
+	}
+	if err := psr.parse(); err != nil {
+		return nil, err
+	}
+	return psr.filter, nil
+}
+
+type filterParser struct {
+	query  string
+	pos    int
+	specs  map[string]*FilterSpec
+	filter *Filter
+	// seen - fields parsed so far, mapped to '>' or '<' if the field was
+	// given as a non-inverted comparison, the other side can be given later
+	seen map[string]string
+}
+
+// token - a value along with its position and whether it was quoted
+type token struct {
+	text   string
+	pos    int
+	quoted bool
+}
+
+func (psr *filterParser) errorf(pos int, format string, args ...any) error {
+	return &FilterSyntaxError{
+		Query: psr.query,
+		Pos:   pos,
+		Msg:   fmt.Sprintf(format, args...),
+	}
+}
+
+func (psr *filterParser) eof() bool {
+	return psr.pos >= len(psr.query)
+}
+
+func (psr *filterParser) peek() byte {
+	if psr.eof() {
+		return 0
+	}
+	return psr.query[psr.pos]
+}
+
+func (psr *filterParser) skipSpaces() {
+	for !psr.eof() && isSpace(psr.peek()) {
+		psr.pos++
+	}
+}
+
+// atEnd - true at the end of a term
+func (psr *filterParser) atEnd() bool {
+	return psr.eof() || isSpace(psr.peek())
+}
+
+func isSpace(chr byte) bool {
+	return chr == ' ' || chr == '\t' || chr == '\r' || chr == '\n'
+}
+
+func (psr *filterParser) consume(str string) bool {
+	if strings.HasPrefix(psr.query[psr.pos:], str) {
+		psr.pos += len(str)
+		return true
+	}
+	return false
+}
+
+func (psr *filterParser) parse() error {
+	psr.seen = map[string]string{}
+	for {
+		psr.skipSpaces()
+		if psr.eof() {
+			return nil
+		}
+		if err := psr.term(); err != nil {
+			return err
+		}
+		if !psr.atEnd() {
+			return psr.errorf(psr.pos, "expected space between terms")
+		}
+	}
+}
+
+func (psr *filterParser) term() error {
+	start := psr.pos
+	invert := psr.consume("-")
+
+	fieldPos := psr.pos
+	for !psr.eof() && isFieldChar(psr.peek()) {
+		psr.pos++
+	}
+	field := psr.query[fieldPos:psr.pos]
+	if field == "" {
+		return psr.errorf(fieldPos, "expected field name")
+	}
+	var spec *FilterSpec
+	if psr.specs != nil {
+		spec = psr.specs[field]
+		if spec == nil && !psr.isJsonKey(field) {
+			return psr.errorf(fieldPos, "unknown field '%s'", field)
+		}
+	}
+
+	opPos := psr.pos
+	op := ""
+	if psr.consume(">=") || psr.consume(">") ||
+		psr.consume("<=") || psr.consume("<") {
+		op = psr.query[opPos:psr.pos]
+	}
+	side := ""
+	if op != "" && !invert {
+		side = op[:1]
+	}
+	if prev, found := psr.seen[field]; found &&
+		(prev == "" || side == "" || prev == side) {
+		return psr.errorf(fieldPos, "field '%s' is given more than once",
+			field)
+	}
+	psr.seen[field] = side
+
+	switch {
+	case op != "":
+		return psr.comparison(field, spec, op, invert, start)
+	case psr.consume("~"):
+		vals, err := psr.values("")
+		if err != nil {
+			return err
+		}
+		matcher := &Matcher{Invert: invert}
+		for _, val := range vals {
+			matcher.Fields = append(matcher.Fields, val.text)
+		}
+		psr.filter.Searches[field] = matcher
+		return nil
+	case psr.consume(":"):
+		return psr.match(field, spec, invert, start)
+	}
+	return psr.errorf(opPos,
+		"expected one of ':', '~', '>', '>=', '<', '<=' after field")
+}
+
+// isJsonKey - checks if the field is a path in a field with FtJson spec
+func (psr *filterParser) isJsonKey(field string) bool {
+	base, _, isPath := strings.Cut(field, ".")
+	spec := psr.specs[base]
+	return isPath && spec != nil && spec.Type == FtJson
+}
+
+func isFieldChar(chr byte) bool {
+	return chr == '_' || chr == '.' ||
+		(chr >= 'a' && chr <= 'z') ||
+		(chr >= 'A' && chr <= 'Z') ||
+		(chr >= '0' && chr <= '9')
+}
+
+// match - value list, list matcher or range after ':'
+func (psr *filterParser) match(
+	field string, spec *FilterSpec, invert bool, start int) error {
+	all := psr.consume("&")
+	if psr.consume("[") {
+		vals, err := psr.values("]")
+		if err != nil {
+			return err
+		}
+		if !psr.consume("]") {
+			return psr.errorf(psr.pos, "expected ']'")
+		}
+		psr.filter.Lists[field] = &Matcher{
+			Invert: invert,
+			Fields: psr.typed(vals, spec),
+			All:    all,
+		}
+		return nil
+	}
+	if all {
+		return psr.errorf(psr.pos, "expected '[' after '&'")
+	}
+
+	if psr.consume("..") {
+		if psr.atEnd() {
+			return psr.rangeOf(field, spec, nil, nil, invert, start)
+		}
+		to, err := psr.value()
+		if err != nil {
+			return err
+		}
+		return psr.rangeOf(field, spec, nil, &to, invert, start)
+	}
+
+	first, err := psr.value()
+	if err != nil {
+		return err
+	}
+	if psr.consume("..") {
+		if psr.atEnd() {
+			return psr.rangeOf(field, spec, &first, nil, invert, start)
+		}
+		to, err := psr.value()
+		if err != nil {
+			return err
+		}
+		return psr.rangeOf(field, spec, &first, &to, invert, start)
+	}
+
+	vals := []token{first}
+	if psr.consume(",") {
+		rest, err := psr.values("")
+		if err != nil {
+			return err
+		}
+		vals = append(vals, rest...)
+	}
+	typed := psr.typed(vals, spec)
+
+	ftype := FtProp
+	if spec != nil {
+		ftype = spec.Type
+	} else if _, isBool := typed[0].(bool); isBool && len(typed) == 1 {
+		ftype = FtBoolean
+	}
+	switch ftype {
+	case FtBoolean:
+		val, ok := typed[0].(bool)
+		if !ok || len(typed) != 1 {
+			return psr.errorf(first.pos, "expected a single boolean value")
+		}
+		psr.filter.Bools[field] = val != invert
+	case FtArray:
+		psr.filter.Lists[field] = &Matcher{Invert: invert, Fields: typed}
+	case FtConstant:
+		psr.filter.Constants[field] = &Matcher{Invert: invert, Fields: typed}
+	case FtSearch:
+		psr.filter.Searches[field] = &Matcher{Invert: invert, Fields: typed}
+	case FtNumRange, FtDateRange:
+		if len(vals) != 1 {
+			return psr.errorf(start, "expected a range for '%s'", field)
+		}
+		return psr.rangeOf(field, spec, &first, &first, invert, start)
+	default:
+		psr.filter.Props[field] = &Matcher{Invert: invert, Fields: typed}
+	}
+	return nil
+}
+
+// comparison - open range given by a comparison operator, or the other bound
+// of the range if the field was given with a comparison already. For '>' and
+// '<' the bound is moved by the smallest amount, a millisecond for dates
+func (psr *filterParser) comparison(
+	field string, spec *FilterSpec, op string, invert bool, start int) error {
+	tok, err := psr.value()
+	if err != nil {
+		return err
+	}
+	num, date, err := psr.bound(tok, spec)
+	if err != nil {
+		return err
+	}
+	lower, strict := op[0] == '>', len(op) == 1
+
+	dr, rg := psr.filter.Dates[field], psr.filter.Ranges[field]
+	if (date != nil && rg != nil) || (num != nil && dr != nil) {
+		return psr.errorf(start,
+			"range bounds for '%s' must be of same type", field)
+	}
+	if date != nil {
+		if dr == nil {
+			dr = &DateRangeMatcher{Invert: invert}
+			dr.From, dr.To = minDate, maxDate
+		}
+		switch {
+		case lower && strict:
+			dr.From, dr.FromExcl = date.Add(time.Millisecond), true
+		case lower:
+			dr.From = *date
+		case strict:
+			dr.To, dr.ToExcl = date.Add(-time.Millisecond), true
+		default:
+			dr.To = *date
+		}
+		if dr.To.Before(dr.From) {
+			return psr.errorf(start, "empty date range for '%s'", field)
+		}
+		psr.filter.Dates[field] = dr
+		return nil
+	}
+
+	if rg == nil {
+		rg = &RangeMatcher{Invert: invert}
+		rg.From, rg.To = -math.MaxFloat64, math.MaxFloat64
+	}
+	switch {
+	case lower && strict:
+		rg.From, rg.FromExcl = math.Nextafter(*num, math.Inf(1)), true
+	case lower:
+		rg.From = *num
+	case strict:
+		rg.To, rg.ToExcl = math.Nextafter(*num, math.Inf(-1)), true
+	default:
+		rg.To = *num
+	}
+	if rg.To < rg.From {
+		return psr.errorf(start, "empty range for '%s'", field)
+	}
+	psr.filter.Ranges[field] = rg
+	return nil
+}
+
+// rangeOf - number or date range, nil bound means the range is open on that
+// side. Both the bounds must be of same type, errors are reported at pos
+func (psr *filterParser) rangeOf(
+	field string,
+	spec *FilterSpec,
+	from, to *token,
+	invert bool,
+	pos int) error {
+	var fromNum, toNum *float64
+	var fromDate, toDate *time.Time
+	var err error
+	if from != nil {
+		if fromNum, fromDate, err = psr.bound(*from, spec); err != nil {
+			return err
+		}
+	}
+	if to != nil {
+		if toNum, toDate, err = psr.bound(*to, spec); err != nil {
+			return err
+		}
+	}
+
+	if fromDate != nil || toDate != nil {
+		if fromNum != nil || toNum != nil {
+			return psr.errorf(pos,
+				"range bounds for '%s' must be of same type", field)
+		}
+		dr := &DateRangeMatcher{Invert: invert}
+		dr.From, dr.To = minDate, maxDate
+		if fromDate != nil {
+			dr.From = *fromDate
+		}
+		if toDate != nil {
+			dr.To = *toDate
+		}
+		if dr.To.Before(dr.From) {
+			return psr.errorf(pos, "empty date range for '%s'", field)
+		}
+		psr.filter.Dates[field] = dr
+		return nil
+	}
+
+	rg := &RangeMatcher{Invert: invert}
+	rg.From, rg.To = -math.MaxFloat64, math.MaxFloat64
+	if fromNum != nil {
+		rg.From = *fromNum
+	}
+	if toNum != nil {
+		rg.To = *toNum
+	}
+	if rg.To < rg.From {
+		return psr.errorf(pos, "empty range for '%s'", field)
+	}
+	psr.filter.Ranges[field] = rg
+	return nil
+}
+
+// bound - range bound as a number or a date, as given by spec or the value
+func (psr *filterParser) bound(
+	tok token, spec *FilterSpec) (*float64, *time.Time, error) {
+	if spec == nil || spec.Type == FtNumRange {
+		if num, err := strconv.ParseFloat(tok.text, 64); err == nil &&
+			!math.IsNaN(num) && !math.IsInf(num, 0) {
+			return &num, nil, nil
+		}
+	}
+	if spec == nil || spec.Type == FtDateRange {
+		if tm, ok := toTime(tok.text); ok {
+			return nil, &tm, nil
+		}
+	}
+	if spec != nil && spec.Type == FtNumRange {
+		return nil, nil, psr.errorf(tok.pos, "expected a number")
+	}
+	if spec != nil && spec.Type == FtDateRange {
+		return nil, nil, psr.errorf(tok.pos, "expected a date")
+	}
+	return nil, nil, psr.errorf(tok.pos, "expected a number or a date")
+}
+
+// values - comma separated values, at least one value is expected
+func (psr *filterParser) values(closing string) ([]token, error) {
+	vals := make([]token, 0, 4)
+	for {
+		if closing != "" && strings.HasPrefix(psr.query[psr.pos:], closing) {
+			if len(vals) == 0 {
+				return nil, psr.errorf(psr.pos, "expected a value")
+			}
+			return vals, nil
+		}
+		val, err := psr.value()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+		if !psr.consume(",") {
+			return vals, nil
+		}
+	}
+}
+
+// value - a quoted string or a bare word, bare words end at spaces, ',', ']'
+// and '..'
+func (psr *filterParser) value() (token, error) {
+	start := psr.pos
+	if psr.consume(`"`) {
+		var sb strings.Builder
+		for {
+			if psr.eof() {
+				return token{}, psr.errorf(start, "unterminated string")
+			}
+			chr := psr.peek()
+			psr.pos++
+			switch chr {
+			case '"':
+				return token{text: sb.String(), pos: start, quoted: true}, nil
+			case '\\':
+				if psr.eof() {
+					return token{}, psr.errorf(psr.pos, "unterminated escape")
+				}
+				sb.WriteByte(psr.peek())
+				psr.pos++
+			default:
+				sb.WriteByte(chr)
+			}
+		}
+	}
+
+	for !psr.eof() {
+		chr := psr.peek()
+		if strings.ContainsRune(" \t\r\n,[]\"\\", rune(chr)) ||
+			strings.HasPrefix(psr.query[psr.pos:], "..") {
+			break
+		}
+		psr.pos++
+	}
+	if psr.pos == start {
+		return token{}, psr.errorf(start, "expected a value")
+	}
+	return token{text: psr.query[start:psr.pos], pos: start}, nil
+}
+
+// typed - values converted to the type given by spec, or inferred for
+// unquoted values
+func (psr *filterParser) typed(vals []token, spec *FilterSpec) []any {
+	out := make([]any, 0, len(vals))
+	for _, val := range vals {
+		if val.quoted || (spec != nil && spec.Type == FtSearch) {
+			out = append(out, val.text)
+			continue
+		}
+		if val.text == "true" || val.text == "false" {
+			out = append(out, val.text == "true")
+			continue
+		}
+		if num, err := strconv.ParseFloat(val.text, 64); err == nil &&
+			!math.IsNaN(num) && !math.IsInf(num, 0) {
+			out = append(out, num)
+			continue
+		}
+		out = append(out, val.text)
+	}
+	return out
+}
+
+// String - the filter in the filter query language. Terms are ordered by the
+// kind of condition and the field. Constants are formatted like props
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	terms := make([]string, 0, 10)
+	for _, field := range sortedFields(f.Bools) {
+		if val, ok := f.Bools[field].(bool); ok {
+			terms = append(terms, field+":"+strconv.FormatBool(val))
+		}
+	}
+	matchers := func(mp map[string]*Matcher, list bool) {
+		for _, field := range sortedFields(mp) {
+			matcher := mp[field]
+			if matcher == nil || len(matcher.Fields) == 0 {
+				continue
+			}
+			vals := make([]string, 0, len(matcher.Fields))
+			for _, val := range matcher.Fields {
+				vals = append(vals, formatValue(val))
+			}
+			joined := strings.Join(vals, ",")
+			if list {
+				joined = Qop(matcher.All, "&[", "[") + joined + "]"
+			}
+			terms = append(terms,
+				Qop(matcher.Invert, "-", "")+field+":"+joined)
+		}
+	}
+	matchers(f.Props, false)
+	matchers(f.Constants, false)
+	matchers(f.Lists, true)
+
+	for _, field := range sortedFields(f.Dates) {
+		dr := f.Dates[field]
+		if dr == nil {
+			continue
+		}
+		hasFrom, hasTo := !dr.From.Equal(minDate), !dr.To.Equal(maxDate)
+		rb := newRangeBounds(dr.Invert, hasFrom && hasTo,
+			dr.FromExcl, dr.ToExcl)
+		if hasFrom {
+			rb.from = formatValue(
+				dr.From.Add(Qop(rb.fromExcl, -time.Millisecond, 0)))
+		}
+		if hasTo {
+			rb.to = formatValue(
+				dr.To.Add(Qop(rb.toExcl, time.Millisecond, 0)))
+		}
+		terms = append(terms, rb.term(field))
+	}
+	for _, field := range sortedFields(f.Ranges) {
+		rg := f.Ranges[field]
+		if rg == nil {
+			continue
+		}
+		hasFrom, hasTo := rg.From != -math.MaxFloat64, rg.To != math.MaxFloat64
+		rb := newRangeBounds(rg.Invert, hasFrom && hasTo,
+			rg.FromExcl, rg.ToExcl)
+		if hasFrom {
+			from := rg.From
+			if rb.fromExcl {
+				from = math.Nextafter(from, math.Inf(-1))
+			}
+			rb.from = formatValue(from)
+		}
+		if hasTo {
+			to := rg.To
+			if rb.toExcl {
+				to = math.Nextafter(to, math.Inf(1))
+			}
+			rb.to = formatValue(to)
+		}
+		terms = append(terms, rb.term(field))
+	}
+
+	for _, field := range sortedFields(f.Searches) {
+		matcher := f.Searches[field]
+		if matcher == nil || len(matcher.Fields) == 0 {
+			continue
+		}
+		vals := make([]string, 0, len(matcher.Fields))
+		for _, val := range matcher.Fields {
+			vals = append(vals, quote(fmt.Sprint(val)))
+		}
+		terms = append(terms, Qop(matcher.Invert, "-", "")+field+"~"+
+			strings.Join(vals, ","))
+	}
+	return strings.Join(terms, " ")
+}
+
+// rangeBounds - formatted bounds of a range, empty for open ends
+type rangeBounds struct {
+	from, to         string
+	fromExcl, toExcl bool
+	invert           bool
+}
+
+// newRangeBounds - bounds with the exclusive flags of a range. A range with
+// an exclusive bound and another bound is given as two comparisons, which can
+// not be inverted together, such inverted ranges are given with inclusive
+// bounds
+func newRangeBounds(invert, closed, fromExcl, toExcl bool) *rangeBounds {
+	excl := !invert || !closed
+	return &rangeBounds{
+		fromExcl: fromExcl && excl,
+		toExcl:   toExcl && excl,
+		invert:   invert,
+	}
+}
+
+func (rb *rangeBounds) term(field string) string {
+	prefix := Qop(rb.invert, "-", "") + field
+	lower := prefix + Qop(rb.fromExcl, ">", ">=") + rb.from
+	upper := prefix + Qop(rb.toExcl, "<", "<=") + rb.to
+	switch {
+	case rb.from == "" && rb.to == "":
+		return prefix + ":.."
+	case rb.to == "":
+		return lower
+	case rb.from == "":
+		return upper
+	case rb.fromExcl || rb.toExcl:
+		return lower + " " + upper
+	}
+	return prefix + ":" + rb.from + ".." + rb.to
+}
+
+func sortedFields[V any](mp map[string]V) []string {
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// formatValue - value in the form that is parsed back to the same value
+func formatValue(val any) string {
+	switch v := val.(type) {
+	case string:
+		if !bareValueRx.MatchString(v) || strings.Contains(v, "..") ||
+			v == "true" || v == "false" {
+			return quote(v)
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return quote(v)
+		}
+		return v
+	case time.Time:
+		if v.Equal(v.Truncate(24*time.Hour)) && v.Location() == time.UTC {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339Nano)
+	case nil:
+		return `""`
+	}
+	switch num := normalize(val).(type) {
+	case float64:
+		return strconv.FormatFloat(num, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(num)
+	}
+	return quote(fmt.Sprint(val))
+}
+
+func quote(str string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for idx := 0; idx < len(str); idx++ {
+		if str[idx] == '"' || str[idx] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(str[idx])
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
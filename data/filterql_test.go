@@ -0,0 +1,196 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSpecs = []*FilterSpec{
+	{Field: "status", Type: FtProp},
+	{Field: "tags", Type: FtArray},
+	{Field: "age", Type: FtNumRange},
+	{Field: "created", Type: FtDateRange},
+	{Field: "name", Type: FtSearch},
+	{Field: "active", Type: FtBoolean},
+	{Field: "metadata", Type: FtJson},
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	tests := []struct {
+		query string
+		// want - formatted filter, same as query if empty
+		want  string
+		specs []*FilterSpec
+	}{
+		{query: "status:active,pending"},
+		{query: "tags:[spam,ads]"},
+		{query: "tags:&[spam,ads]"},
+		{query: "-tags:[spam]"},
+		{query: "n:1.5,2"},
+		{query: `city:"New York","a\"b\\c"`},
+		{query: `code:"42",x,"true"`},
+		{query: "active:true"},
+		{query: "-active:true", want: "active:false"},
+		{query: "age:20..40"},
+		{query: "-age:20..40"},
+		{query: "age:20..", want: "age>=20"},
+		{query: "age:..40", want: "age<=40"},
+		{query: "age:..", want: "age:.."},
+		{query: "age>=20"},
+		{query: "age<=40"},
+		{query: "age>20"},
+		{query: "age<40"},
+		{query: "-age>20"},
+		{query: "age>=20  age<40", want: "age>=20 age<40"},
+		{query: "age<40 age>20", want: "age>20 age<40"},
+		{query: "age>=20 age<=40", want: "age:20..40"},
+		{query: "age<0.1"},
+		{query: "created>2024-01-01"},
+		{query: "created<2024-01-01"},
+		{query: "created:2024-01-01..2024-02-01"},
+		{query: "created>=2024-01-01T10:30:00Z"},
+		{
+			query: "created>2024-01-01 created<=2024-02-01T10:00:00.5Z",
+		},
+		{query: `name~"jo%"`},
+		{query: `-name~"a b","c"`},
+		{
+			query: `name~jo% status:a age>1 tags:[x] active:false`,
+			want:  `active:false status:a tags:[x] age>1 name~"jo%"`,
+		},
+		{
+			query: "status:1 age:5 created:2024-01-01 metadata.plan:pro",
+			want: "metadata.plan:pro status:1 " +
+				"created:2024-01-01..2024-01-01 age:5..5",
+			specs: testSpecs,
+		},
+		{
+			query: "name~jo active:true",
+			want:  `active:true name~"jo"`,
+			specs: testSpecs,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			want := test.want
+			if want == "" {
+				want = test.query
+			}
+			filter, err := ParseFilter(test.query, test.specs...)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			got := filter.String()
+			if got != want {
+				t.Fatalf("expected '%s', got '%s'", want, got)
+			}
+			again, err := ParseFilter(got, test.specs...)
+			if err != nil {
+				t.Fatalf("failed to parse formatted filter: %v", err)
+			}
+			if !reflect.DeepEqual(filter, again) {
+				t.Fatalf("filter changed after formatting, got '%s'",
+					again.String())
+			}
+		})
+	}
+}
+
+func TestFilterSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+		msg    string
+		specs  []*FilterSpec
+	}{
+		{query: "status", column: 7, msg: "expected one of"},
+		{query: "status:", column: 8, msg: "expected a value"},
+		{query: "x:1  y", column: 7, msg: "expected one of"},
+		{query: "-:x", column: 2, msg: "expected field name"},
+		{query: `name:"abc`, column: 6, msg: "unterminated string"},
+		{query: `name:"abc\`, column: 11, msg: "unterminated escape"},
+		{query: "a:1,]", column: 5, msg: "expected a value"},
+		{query: "a:x\"y\"", column: 4, msg: "expected space"},
+		{query: "tags:[a", column: 8, msg: "expected ']'"},
+		{query: "tags:[]", column: 7, msg: "expected a value"},
+		{query: "tags:&a", column: 7, msg: "expected '[' after '&'"},
+		{query: "age:5..1", column: 1, msg: "empty range"},
+		{query: "x:a..b", column: 3, msg: "expected a number or a date"},
+		{query: "a:1 a:2", column: 5, msg: "given more than once"},
+		{query: "age>20 age>30", column: 8, msg: "given more than once"},
+		{query: "age>20 age:30", column: 8, msg: "given more than once"},
+		{query: "-age>20 -age<30", column: 10, msg: "given more than once"},
+		{query: "age>5 age<3", column: 7, msg: "empty range"},
+		{
+			query:  "age>5 age<2024-01-01",
+			column: 7,
+			msg:    "must be of same type",
+		},
+		{
+			query:  "created>2024-02-01 created<2024-01-01",
+			column: 20,
+			msg:    "empty date range",
+		},
+		{
+			query:  "foo:1",
+			column: 1,
+			msg:    "unknown field",
+			specs:  testSpecs,
+		},
+		{
+			query:  "status:a tags.x:1",
+			column: 10,
+			msg:    "unknown field",
+			specs:  testSpecs,
+		},
+		{
+			query:  "age:abc",
+			column: 5,
+			msg:    "expected a number",
+			specs:  testSpecs,
+		},
+		{
+			query:  "created>abc",
+			column: 9,
+			msg:    "expected a date",
+			specs:  testSpecs,
+		},
+		{
+			query:  "active:1",
+			column: 8,
+			msg:    "expected a single boolean",
+			specs:  testSpecs,
+		},
+		{
+			query:  "age:1,2",
+			column: 1,
+			msg:    "expected a range",
+			specs:  testSpecs,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := ParseFilter(test.query, test.specs...)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Fatalf("expected ErrInvalidFilter, got %v", err)
+			}
+			var fse *FilterSyntaxError
+			if !errors.As(err, &fse) {
+				t.Fatalf("expected *FilterSyntaxError, got %T", err)
+			}
+			if fse.Pos+1 != test.column {
+				t.Fatalf("expected error at column %d, got %v",
+					test.column, err)
+			}
+			if !strings.Contains(fse.Msg, test.msg) {
+				t.Fatalf("expected error with '%s', got '%s'",
+					test.msg, fse.Msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+// MemStore - GetterDeleter that keeps items in memory, useful for tests,
+// caches and small datasets loaded from configuration. Items of a data type
+// are kept in the order they are added, filter semantics are same as that of
+// Apply. Items are stored as given, pointers are not copied
+type MemStore struct {
+	mutex sync.RWMutex
+	items map[string][]any
+}
+
+// NewMemStore - creates an empty in-memory store
+func NewMemStore() *MemStore {
+	return &MemStore{
+		items: map[string][]any{},
+	}
+}
+
+// Put - adds items of the data type, nil items and nil pointers are skipped
+func (ms *MemStore) Put(dtype string, items ...any) *MemStore {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	for _, item := range items {
+		val := reflect.ValueOf(item)
+		if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+			continue
+		}
+		ms.items[dtype] = append(ms.items[dtype], item)
+	}
+	return ms
+}
+
+// PutSlice - adds the elements of given slice as items of the data type
+func (ms *MemStore) PutSlice(dtype string, slice any) error {
+	val := reflect.ValueOf(slice)
+	if val.Kind() != reflect.Slice {
+		return errx.Errf(ErrInvalidData,
+			"expected a slice of items for '%s', got '%T'", dtype, slice)
+	}
+	items := make([]any, 0, val.Len())
+	for idx := 0; idx < val.Len(); idx++ {
+		items = append(items, val.Index(idx).Interface())
+	}
+	ms.Put(dtype, items...)
+	return nil
+}
+
+// Items - all the items of the data type
+func (ms *MemStore) Items(dtype string) []any {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return slices.Clone(ms.items[dtype])
+}
+
+// records - records for the items, must be called with lock held
+func (ms *MemStore) records(dtype string) []*record {
+	recs := make([]*record, 0, len(ms.items[dtype]))
+	for idx, item := range ms.items[dtype] {
+		recs = append(recs, newRecord(idx, item))
+	}
+	return recs
+}
+
+// find - index of the first item whose key field has the given value, must
+// be called with lock held
+func (ms *MemStore) find(dtype, keyField string, key any) int {
+	for _, rec := range ms.records(dtype) {
+		val, found := rec.value(keyField)
+		if found && equalValues(val, normalize(key)) {
+			return rec.index
+		}
+	}
+	return -1
+}
+
+func (ms *MemStore) Exists(
+	_ context.Context, dtype, keyField string, id any) (bool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return ms.find(dtype, keyField, id) != -1, nil
+}
+
+func (ms *MemStore) Count(
+	_ context.Context, dtype string, filter *Filter) (int64, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	recs, err := apply(ms.records(dtype), filter, nil)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(recs)), nil
+}
+
+func (ms *MemStore) GetOne(
+	_ context.Context,
+	dataType string,
+	keyField string,
+	key any,
+	dataOut any) error {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	idx := ms.find(dataType, keyField, key)
+	if idx == -1 {
+		return errx.Errf(ErrNotFound, "no item in '%s' with '%s' = '%v'",
+			dataType, keyField, key)
+	}
+	out := reflect.ValueOf(dataOut)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return errx.Errf(ErrInvalidData,
+			"expected a pointer to get item into, got '%T'", dataOut)
+	}
+	return assign(out.Elem(), ms.items[dataType][idx])
+}
+
+func (ms *MemStore) Get(
+	_ context.Context,
+	dtype string,
+	params *CommonParams,
+	out any) error {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.Elem().Kind() != reflect.Slice {
+		return errx.Errf(ErrInvalidData,
+			"expected a pointer to slice to get items into, got '%T'", out)
+	}
+	recs, err := apply(ms.records(dtype), nil, params)
+	if err != nil {
+		return err
+	}
+
+	slice := outVal.Elem()
+	res := reflect.MakeSlice(slice.Type(), len(recs), len(recs))
+	for idx, rec := range recs {
+		err := assign(res.Index(idx), ms.items[dtype][rec.index])
+		if err != nil {
+			return err
+		}
+	}
+	slice.Set(res)
+	return nil
+}
+
+func (ms *MemStore) FilterValues(
+	_ context.Context,
+	dtype string,
+	specs []*FilterSpec,
+	filter *Filter) (*FilterValues, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	return filterValues(ms.records(dtype), specs, filter)
+}
+
+func (ms *MemStore) Delete(
+	_ context.Context,
+	dataType string,
+	keyField string,
+	keys ...any) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	wanted := &Matcher{Fields: keys}
+	matches := inPredicate(keyField, wanted)
+	remaining := make([]any, 0, len(ms.items[dataType]))
+	for _, rec := range ms.records(dataType) {
+		if !matches(rec) {
+			remaining = append(remaining, ms.items[dataType][rec.index])
+		}
+	}
+	ms.items[dataType] = remaining
+	return nil
+}
+
+// assign - sets the item to target, dereferencing or taking address of the
+// item as required
+func assign(target reflect.Value, item any) error {
+	val := reflect.ValueOf(item)
+	switch {
+	case val.Type().AssignableTo(target.Type()):
+		target.Set(val)
+	case val.Kind() == reflect.Ptr &&
+		val.Type().Elem().AssignableTo(target.Type()):
+		target.Set(val.Elem())
+	case target.Kind() == reflect.Ptr &&
+		val.Type().AssignableTo(target.Type().Elem()):
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		target.Set(ptr)
+	default:
+		return errx.Errf(ErrInvalidData,
+			"item of type '%s' cannot be assigned to '%s'",
+			val.Type(), target.Type())
+	}
+	return nil
+}
+
+// FilterValuesOf - values and facet counts for the filter specs computed
+// from items in memory, see GetFilterValues in the database packages
+func FilterValuesOf[T any](
+	items []T, specs []*FilterSpec, filter *Filter) (*FilterValues, error) {
+	recs := make([]*record, 0, len(items))
+	for idx := range items {
+		recs = append(recs, newRecord(idx, &items[idx]))
+	}
+	return filterValues(recs, specs, filter)
+}
+
+func filterValues(
+	recs []*record,
+	specs []*FilterSpec,
+	filter *Filter) (*FilterValues, error) {
+	fvals := NewFilterValues()
+	for _, spec := range specs {
+		switch spec.Type {
+		case FtProp, FtArray, FtDateRange, FtNumRange:
+		default:
+			continue
+		}
+
+		matched, err := apply(recs, filter.Without(spec.Field), nil)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Type == FtProp || spec.Type == FtArray {
+			facet := valueFacet(matched, spec)
+			vals := make([]any, 0, len(facet.Values))
+			for _, fv := range facet.Values {
+				vals = append(vals, fv.Value)
+			}
+			fvals.Facets[spec.Field] = facet
+			fvals.Values[spec.Field] = vals
+			continue
+		}
+		fvals.Facets[spec.Field] = rangeFacet(matched, spec, fvals)
+	}
+	return fvals, nil
+}
+
+func valueFacet(recs []*record, spec *FilterSpec) *Facet {
+	counts := make([]FacetValue, 0, 100)
+	index := map[any]int{}
+	for _, rec := range recs {
+		for _, val := range rec.values(spec.Field) {
+			key := val
+			if tm, ok := val.(time.Time); ok {
+				key = tm.UnixNano()
+			} else if !reflect.TypeOf(val).Comparable() {
+				continue
+			}
+			if idx, found := index[key]; found {
+				counts[idx].Count++
+				continue
+			}
+			index[key] = len(counts)
+			counts = append(counts, FacetValue{Value: val, Count: 1})
+		}
+	}
+
+	slices.SortStableFunc(counts, func(a, b FacetValue) int {
+		if a.Count != b.Count {
+			return Qop(a.Count > b.Count, -1, 1)
+		}
+		return compareValues(a.Value, b.Value)
+	})
+
+	facet := &Facet{Values: counts}
+	if spec.TopN > 0 && len(counts) > spec.TopN {
+		for _, fv := range counts[spec.TopN:] {
+			facet.Other += fv.Count
+		}
+		facet.Values = counts[:spec.TopN]
+	}
+	return facet
+}
+
+func rangeFacet(
+	recs []*record, spec *FilterSpec, fvals *FilterValues) *Facet {
+	nums := make([]float64, 0, len(recs))
+	for _, rec := range recs {
+		val, found := rec.value(spec.Field)
+		if !found {
+			continue
+		}
+		if spec.Type == FtDateRange {
+			if tm, ok := toTime(val); ok {
+				nums = append(nums, float64(tm.UnixMicro())/1e6)
+			}
+		} else if num, ok := toFloat(val); ok {
+			nums = append(nums, num)
+		}
+	}
+
+	facet := &Facet{}
+	if len(nums) == 0 {
+		return facet
+	}
+	lo, hi := slices.Min(nums), slices.Max(nums)
+	counts := make([]int64, spec.NumBuckets())
+	for _, num := range nums {
+		idx := 0
+		if hi != lo {
+			idx = min(len(counts)-1,
+				int(float64(len(counts))*(num-lo)/(hi-lo)))
+		}
+		counts[idx]++
+	}
+
+	if spec.Type == FtDateRange {
+		facet.Buckets = NewBuckets(lo, hi, counts, FromEpoch)
+		fvals.Dates[spec.Field] = &DateRange{
+			From: EpochTime(lo),
+			To:   EpochTime(hi),
+		}
+		return facet
+	}
+	facet.Buckets = NewBuckets(lo, hi, counts, AsNumber)
+	fvals.Ranges[spec.Field] = &NumberRange{From: lo, To: hi}
+	return facet
+}
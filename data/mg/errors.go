@@ -0,0 +1,46 @@
+package mg
+
+import (
+	"errors"
+
+	"github.com/varunamachi/libx/errx"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongo error code for write conflicts in transactions
+const codeWriteConflict = 112
+
+func init() {
+	errx.RegisterClassifier(classify)
+}
+
+// classify - classifies mongo errors so that errx.IsNotFound and friends work
+// for them
+func classify(err error) errx.Kind {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errx.KindNotFound
+	}
+
+	var kind errx.Kind
+	if mongo.IsDuplicateKeyError(err) {
+		kind |= errx.KindConflict
+	}
+	if mongo.IsTimeout(err) {
+		kind |= errx.KindTimeout | errx.KindRetryable
+	}
+	if mongo.IsNetworkError(err) {
+		kind |= errx.KindRetryable
+	}
+
+	var se mongo.ServerError
+	if errors.As(err, &se) {
+		if se.HasErrorLabel("TransientTransactionError") ||
+			se.HasErrorLabel("RetryableWriteError") {
+			kind |= errx.KindRetryable
+		}
+		if se.HasErrorCode(codeWriteConflict) {
+			kind |= errx.KindConflict | errx.KindRetryable
+		}
+	}
+	return kind
+}
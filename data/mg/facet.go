@@ -0,0 +1,208 @@
+package mg
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetFilterValues - values and facet counts for the filter specs computed
+// in a single aggregation using $facet. Facet of a field is computed with the
+// filter excluding the field's own condition, array fields are unwound so
+// that elements are counted individually. If TopN is given for a spec, only
+// those many values are given and rest are counted in Facet.Other. Range
+// fields get extremes and a histogram, which requires MongoDB 5.0 or later
+func GetFilterValues(
+	gtx context.Context,
+	coll *mongo.Collection,
+	specs []*data.FilterSpec,
+	filter *data.Filter) (*data.FilterValues, error) {
+
+	fvals := data.NewFilterValues()
+	stages := bson.M{}
+	for idx, spec := range specs {
+		name := "f" + strconv.Itoa(idx)
+		switch spec.Type {
+		case data.FtProp, data.FtArray:
+			stages[name] = valueFacet(spec, filter)
+		case data.FtDateRange, data.FtNumRange:
+			stages[name] = bucketFacet(spec, filter)
+		}
+	}
+	if len(stages) == 0 {
+		return fvals, nil
+	}
+
+	cur, err := coll.Aggregate(
+		gtx, mongo.Pipeline{{{Key: "$facet", Value: stages}}})
+	if err != nil {
+		return nil, errx.Errf(err, "failed to get filter values for '%s'",
+			coll.Name())
+	}
+	defer cur.Close(gtx)
+
+	var res bson.Raw
+	if cur.Next(gtx) {
+		res = cur.Current
+	}
+	if err := cur.Err(); err != nil {
+		return nil, errx.Errf(err, "failed to read filter values for '%s'",
+			coll.Name())
+	}
+
+	for idx, spec := range specs {
+		rv, err := res.LookupErr("f" + strconv.Itoa(idx))
+		if err != nil {
+			continue
+		}
+		switch spec.Type {
+		case data.FtProp, data.FtArray:
+			err = readValues(rv, spec, fvals)
+		case data.FtDateRange, data.FtNumRange:
+			err = readBuckets(rv, spec, fvals)
+		}
+		if err != nil {
+			return nil, errx.Errf(err,
+				"failed to decode filter values of '%s'", spec.Field)
+		}
+	}
+	return fvals, nil
+}
+
+// valueFacet - pipeline giving a single document with values sorted by
+// count and number of other values
+func valueFacet(spec *data.FilterSpec, filter *data.Filter) bson.A {
+	field := "$" + spec.Field
+	pipe := bson.A{bson.M{"$match": Selector(filter.Without(spec.Field))}}
+	if spec.Type == data.FtArray {
+		pipe = append(pipe, bson.M{"$unwind": field})
+	}
+	pipe = append(pipe,
+		bson.M{"$match": bson.M{spec.Field: bson.M{"$ne": nil}}},
+		bson.M{"$group": bson.M{"_id": field, "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{
+			{Key: "count", Value: -1},
+			{Key: "_id", Value: 1},
+		}},
+		bson.M{"$group": bson.M{
+			"_id": nil,
+			"values": bson.M{
+				"$push": bson.M{"value": "$_id", "count": "$count"},
+			},
+		}},
+	)
+	if spec.TopN > 0 {
+		pipe = append(pipe, bson.M{"$project": bson.M{
+			"values": bson.M{"$slice": bson.A{"$values", spec.TopN}},
+			"other": bson.M{"$subtract": bson.A{
+				bson.M{"$sum": "$values.count"},
+				bson.M{"$sum": bson.M{
+					"$slice": bson.A{"$values.count", spec.TopN},
+				}},
+			}},
+		}})
+	}
+	return pipe
+}
+
+// bucketFacet - pipeline giving equal width histogram buckets with zero
+// based bucket index as _id. Dates are converted to milliseconds since epoch
+func bucketFacet(spec *data.FilterSpec, filter *data.Filter) bson.A {
+	num := spec.NumBuckets()
+	whole := bson.M{"documents": bson.A{"unbounded", "unbounded"}}
+	return bson.A{
+		bson.M{"$match": Selector(filter.Without(spec.Field))},
+		bson.M{"$match": bson.M{spec.Field: bson.M{"$ne": nil}}},
+		bson.M{"$project": bson.M{
+			"_v": bson.M{"$toDouble": "$" + spec.Field},
+		}},
+		bson.M{"$setWindowFields": bson.M{"output": bson.M{
+			"_lo": bson.M{"$min": "$_v", "window": whole},
+			"_hi": bson.M{"$max": "$_v", "window": whole},
+		}}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$_hi", "$_lo"}},
+				0,
+				bson.M{"$min": bson.A{num - 1, bson.M{"$floor": bson.M{
+					"$divide": bson.A{
+						bson.M{"$multiply": bson.A{
+							num, bson.M{"$subtract": bson.A{"$_v", "$_lo"}},
+						}},
+						bson.M{"$subtract": bson.A{"$_hi", "$_lo"}},
+					},
+				}}}},
+			}},
+			"count": bson.M{"$sum": 1},
+			"lo":    bson.M{"$first": "$_lo"},
+			"hi":    bson.M{"$first": "$_hi"},
+		}},
+	}
+}
+
+func readValues(
+	rv bson.RawValue, spec *data.FilterSpec, fvals *data.FilterValues) error {
+	var docs []struct {
+		Values []data.FacetValue `bson:"values"`
+		Other  int64             `bson:"other"`
+	}
+	if err := rv.Unmarshal(&docs); err != nil {
+		return err
+	}
+
+	facet := &data.Facet{}
+	vals := make([]any, 0, 100)
+	for _, doc := range docs {
+		facet.Values = append(facet.Values, doc.Values...)
+		facet.Other += doc.Other
+		for _, fv := range doc.Values {
+			vals = append(vals, fv.Value)
+		}
+	}
+	fvals.Facets[spec.Field] = facet
+	fvals.Values[spec.Field] = vals
+	return nil
+}
+
+func readBuckets(
+	rv bson.RawValue, spec *data.FilterSpec, fvals *data.FilterValues) error {
+	var docs []struct {
+		Index float64 `bson:"_id"`
+		Count int64   `bson:"count"`
+		Lo    float64 `bson:"lo"`
+		Hi    float64 `bson:"hi"`
+	}
+	if err := rv.Unmarshal(&docs); err != nil {
+		return err
+	}
+
+	facet := &data.Facet{}
+	fvals.Facets[spec.Field] = facet
+	if len(docs) == 0 {
+		return nil
+	}
+
+	counts := make([]int64, spec.NumBuckets())
+	for _, doc := range docs {
+		if idx := int(doc.Index); idx >= 0 && idx < len(counts) {
+			counts[idx] = doc.Count
+		}
+	}
+	lo, hi := docs[0].Lo, docs[0].Hi
+	if spec.Type == data.FtDateRange {
+		lo, hi = lo/1000, hi/1000
+		facet.Buckets = data.NewBuckets(lo, hi, counts, data.FromEpoch)
+		fvals.Dates[spec.Field] = &data.DateRange{
+			From: data.EpochTime(lo),
+			To:   data.EpochTime(hi),
+		}
+		return nil
+	}
+	facet.Buckets = data.NewBuckets(lo, hi, counts, data.AsNumber)
+	fvals.Ranges[spec.Field] = &data.NumberRange{From: lo, To: hi}
+	return nil
+}
@@ -0,0 +1,178 @@
+package mg
+
+import (
+	"regexp"
+
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Selector - translates the filter into a mongo query document. All the
+// conditions in the filter are ANDed
+func Selector(filter *data.Filter) bson.M {
+	if filter == nil {
+		return bson.M{}
+	}
+
+	conds := make(bson.A, 0, 10)
+	addIn := func(matchers map[string]*data.Matcher) {
+		for field, matcher := range matchers {
+			if matcher == nil || len(matcher.Fields) == 0 {
+				continue
+			}
+			cond := bson.M{"$in": matcher.Fields}
+			if matcher.All {
+				cond = bson.M{"$all": matcher.Fields}
+			}
+			if matcher.Invert {
+				cond = bson.M{"$not": cond}
+			}
+			conds = append(conds, bson.M{field: cond})
+		}
+	}
+
+	// $in and $all work for both scalar and array fields
+	addIn(filter.Props)
+	addIn(filter.Lists)
+	addIn(filter.Constants)
+
+	for field, val := range filter.Bools {
+		if val != nil {
+			conds = append(conds, bson.M{field: val})
+		}
+	}
+
+	for field, dr := range filter.Dates {
+		if dr == nil || !dr.IsValid() {
+			continue
+		}
+		conds = append(conds,
+			rangeCond(field, dr.From, dr.To, dr.Invert))
+	}
+
+	for field, rg := range filter.Ranges {
+		if rg == nil || !rg.IsValid() {
+			continue
+		}
+		conds = append(conds,
+			rangeCond(field, rg.From, rg.To, rg.Invert))
+	}
+
+	for field, matcher := range filter.Searches {
+		if matcher == nil || len(matcher.Fields) == 0 {
+			continue
+		}
+		alts := make(bson.A, 0, len(matcher.Fields))
+		for _, val := range matcher.Fields {
+			str, ok := val.(string)
+			if !ok {
+				continue
+			}
+			alts = append(alts, bson.M{field: bson.M{
+				"$regex":   regexp.QuoteMeta(str),
+				"$options": "i",
+			}})
+		}
+		if len(alts) == 0 {
+			continue
+		}
+		conds = append(conds, bson.M{
+			data.Qop(matcher.Invert, "$nor", "$or"): alts,
+		})
+	}
+
+	if len(conds) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": conds}
+}
+
+func rangeCond(field string, from, to any, invert bool) bson.M {
+	cond := bson.M{"$gte": from, "$lte": to}
+	if invert {
+		return bson.M{field: bson.M{"$not": cond}}
+	}
+	return bson.M{field: cond}
+}
+
+// FindOpts - options for paging and sorting based on common params, see
+// SortDoc for the sorts that are supported
+func FindOpts(params *data.CommonParams) (*options.FindOptions, error) {
+	opts := options.Find()
+	if params == nil {
+		return opts, nil
+	}
+	if params.Limit() > 0 {
+		opts.SetSkip(params.Offset()).SetLimit(params.Limit())
+	}
+	sort, err := SortDoc(params.SortFields())
+	if err != nil {
+		return nil, err
+	}
+	if len(sort) != 0 {
+		opts.SetSort(sort)
+	}
+	return opts, nil
+}
+
+// SortDoc - sort document for the sort fields. Mongo always orders nulls
+// before other values, so nulls are first in ascending and last in descending
+// order. Sort fields asking for the other order give ErrInvalidSort
+func SortDoc(sorts []data.SortField) (bson.D, error) {
+	sort := make(bson.D, 0, len(sorts))
+	for _, sf := range sorts {
+		if (sf.Desc && sf.Nulls == data.NullsFirst) ||
+			(!sf.Desc && sf.Nulls == data.NullsLast) {
+			order := data.Qop(sf.Desc, "descending", "ascending")
+			return nil, errx.Errf(data.ErrInvalidSort,
+				"nulls can not be %s when sorting by '%s' in %s order",
+				sf.Nulls, sf.Field, order)
+		}
+		sort = append(sort,
+			bson.E{Key: sf.Field, Value: data.Qop(sf.Desc, -1, 1)})
+	}
+	return sort, nil
+}
+
+// KeysetSelector - condition selecting documents after the keyset position,
+// nil if the keyset has no position
+func KeysetSelector(ks *data.Keyset) bson.M {
+	if ks == nil || ks.Values == nil {
+		return nil
+	}
+	desc := ks.QueryDesc()
+	alts := make(bson.A, 0, len(ks.Fields))
+	for idx, field := range ks.Fields {
+		cond := bson.M{}
+		for jdx := 0; jdx < idx; jdx++ {
+			cond[ks.Fields[jdx]] = ks.Values[jdx]
+		}
+		op := data.Qop(desc[idx], "$lt", "$gt")
+		cond[field] = bson.M{op: ks.Values[idx]}
+		alts = append(alts, cond)
+	}
+	return bson.M{"$or": alts}
+}
+
+// KeysetOpts - options for sorting and limiting as required by the keyset,
+// one document more than page size is fetched
+func KeysetOpts(
+	ks *data.Keyset, params *data.CommonParams) *options.FindOptions {
+	desc := ks.QueryDesc()
+	sort := make(bson.D, 0, len(ks.Fields))
+	for idx, field := range ks.Fields {
+		sort = append(sort,
+			bson.E{Key: field, Value: data.Qop(desc[idx], -1, 1)})
+	}
+
+	opts := options.Find().SetSort(sort)
+	if params.Limit() > 0 {
+		opts.SetLimit(params.Limit() + 1)
+		if ks.Values == nil && params.Offset() > 0 {
+			opts.SetSkip(params.Offset())
+		}
+	}
+	return opts
+}
@@ -0,0 +1,244 @@
+package mg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type repository[T any] struct {
+	coll *mongo.Collection
+	info *data.ModelInfo
+}
+
+// NewRepository - creates a repository for T backed by given collection, if
+// coll is nil, the collection named in 'bson' tags of T in the default
+// database is used. See data.NewModelInfo for the tags. Panics if T is not a
+// valid model
+func NewRepository[T any](coll *mongo.Collection) data.Repository[T] {
+	info, err := data.NewModelInfo[T]("bson")
+	if err != nil {
+		panic(err)
+	}
+	return &repository[T]{
+		coll: coll,
+		info: info,
+	}
+}
+
+func (repo *repository[T]) c() *mongo.Collection {
+	if repo.coll != nil {
+		return repo.coll
+	}
+	return C(repo.info.Name)
+}
+
+func (repo *repository[T]) byKey(key any) bson.M {
+	return bson.M{repo.info.Key.Name: key}
+}
+
+func (repo *repository[T]) Info() *data.ModelInfo {
+	return repo.info
+}
+
+func (repo *repository[T]) Get(gtx context.Context, key any) (*T, error) {
+	var out T
+	err := repo.c().FindOne(gtx, repo.byKey(key)).Decode(&out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errx.With(errx.Errf(data.ErrNotFound,
+			"no item in '%s'", repo.info.Name), "key", key)
+	}
+	if err != nil {
+		return nil, errx.With(
+			errx.Errf(err, "failed to get item from '%s'", repo.info.Name),
+			"key", key)
+	}
+	return &out, nil
+}
+
+func (repo *repository[T]) List(
+	gtx context.Context, params *data.CommonParams) ([]T, error) {
+	var filter *data.Filter
+	if params != nil {
+		filter = params.Filter
+	}
+
+	opts, err := FindOpts(params)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := repo.c().Find(gtx, Selector(filter), opts)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to list items from '%s'",
+			repo.info.Name)
+	}
+	out := make([]T, 0, 100)
+	if err := ReadAllAndClose(gtx, cur, &out); err != nil {
+		return nil, errx.Errf(err, "failed to read items from '%s'",
+			repo.info.Name)
+	}
+	return out, nil
+}
+
+func (repo *repository[T]) ListPage(
+	gtx context.Context, params *data.CommonParams) (*data.Page[T], error) {
+	if params == nil {
+		params = &data.CommonParams{}
+	}
+	ks, err := data.NewKeyset(repo.info, params)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := Selector(params.Filter)
+	if cond := KeysetSelector(ks); cond != nil {
+		sel = bson.M{"$and": bson.A{sel, cond}}
+	}
+	cur, err := repo.c().Find(gtx, sel, KeysetOpts(ks, params))
+	if err != nil {
+		return nil, errx.Errf(err, "failed to list items from '%s'",
+			repo.info.Name)
+	}
+	items := make([]T, 0)
+	if err := ReadAllAndClose(gtx, cur, &items); err != nil {
+		return nil, errx.Errf(err, "failed to read items from '%s'",
+			repo.info.Name)
+	}
+	return data.NewPage(repo.info, ks, params, items), nil
+}
+
+func (repo *repository[T]) Count(
+	gtx context.Context, filter *data.Filter) (int64, error) {
+	count, err := repo.c().CountDocuments(gtx, Selector(filter))
+	if err != nil {
+		return 0, errx.Errf(err, "failed to count items in '%s'",
+			repo.info.Name)
+	}
+	return count, nil
+}
+
+func (repo *repository[T]) Exists(gtx context.Context, key any) (bool, error) {
+	count, err := repo.c().CountDocuments(
+		gtx, repo.byKey(key), options.Count().SetLimit(1))
+	if err != nil {
+		return false, errx.Errf(err,
+			"failed to check existence of item in '%s'", repo.info.Name)
+	}
+	return count != 0, nil
+}
+
+func (repo *repository[T]) FilterValues(
+	gtx context.Context,
+	specs []*data.FilterSpec,
+	filter *data.Filter) (*data.FilterValues, error) {
+	return GetFilterValues(gtx, repo.c(), specs, filter)
+}
+
+func (repo *repository[T]) Insert(gtx context.Context, item *T) error {
+	res, err := repo.c().InsertOne(gtx, item)
+	if err != nil {
+		return errx.Errf(err, "failed to insert item into '%s'",
+			repo.info.Name)
+	}
+	if repo.info.IsNewItem(item) {
+		return repo.info.SetKey(item, res.InsertedID)
+	}
+	return nil
+}
+
+func (repo *repository[T]) BulkInsert(gtx context.Context, items []*T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	docs := make([]any, 0, len(items))
+	for _, item := range items {
+		docs = append(docs, item)
+	}
+
+	res, err := repo.c().InsertMany(gtx, docs)
+	if err != nil {
+		return errx.Errf(err, "failed to bulk insert into '%s'",
+			repo.info.Name)
+	}
+	for idx, id := range res.InsertedIDs {
+		if idx < len(items) && repo.info.IsNewItem(items[idx]) {
+			if err := repo.info.SetKey(items[idx], id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (repo *repository[T]) Update(gtx context.Context, item *T) error {
+	key := repo.info.KeyValue(item)
+	res, err := repo.c().ReplaceOne(gtx, repo.byKey(key), item)
+	if err != nil {
+		return errx.Errf(err, "failed to update item in '%s'", repo.info.Name)
+	}
+	if res.MatchedCount == 0 {
+		return errx.With(errx.Errf(data.ErrNotFound,
+			"no item to update in '%s'", repo.info.Name), "key", key)
+	}
+	return nil
+}
+
+func (repo *repository[T]) UpdateFields(
+	gtx context.Context, key any, fields map[string]any) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	for name := range fields {
+		if err := repo.info.CheckFields(name); err != nil {
+			return err
+		}
+		if name == repo.info.Key.Name {
+			return errx.Errf(data.ErrUnknownField,
+				"key field '%s' cannot be updated", name)
+		}
+	}
+
+	res, err := repo.c().UpdateOne(
+		gtx, repo.byKey(key), bson.M{"$set": fields})
+	if err != nil {
+		return errx.Errf(err, "failed to update item in '%s'", repo.info.Name)
+	}
+	if res.MatchedCount == 0 {
+		return errx.With(errx.Errf(data.ErrNotFound,
+			"no item to update in '%s'", repo.info.Name), "key", key)
+	}
+	return nil
+}
+
+func (repo *repository[T]) Upsert(gtx context.Context, item *T) error {
+	if repo.info.IsNewItem(item) {
+		return repo.Insert(gtx, item)
+	}
+	_, err := repo.c().ReplaceOne(
+		gtx,
+		repo.byKey(repo.info.KeyValue(item)),
+		item,
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return errx.Errf(err, "failed to upsert item into '%s'",
+			repo.info.Name)
+	}
+	return nil
+}
+
+func (repo *repository[T]) Delete(gtx context.Context, keys ...any) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := repo.c().DeleteMany(
+		gtx, bson.M{repo.info.Key.Name: bson.M{"$in": keys}})
+	if err != nil {
+		return errx.Errf(err, "failed to delete from '%s'", repo.info.Name)
+	}
+	return nil
+}
@@ -28,11 +28,35 @@ const FtSearch FilterType = "Search"
 // FtConstant - constant filter value
 const FtConstant FilterType = "Constant"
 
+// FtJson - filter on keys within a JSON field, a key is given in filters as
+// the field followed by the path, e.g. 'metadata.plan' or 'metadata.a.b'
+const FtJson FilterType = "Json"
+
 // FilterSpec - filter specification
 type FilterSpec struct {
 	Field string     `json:"field" db:"field" bson:"field"`
 	Name  string     `json:"name" db:"name" bson:"name"`
 	Type  FilterType `json:"type" db:"type" bson:"type"`
+	// Column - column/field in the store if it is different from Field, not
+	// exposed to clients
+	Column string `json:"-" db:"column" bson:"column"`
+	// TopN - number of values with counts given for Prop and Array facets,
+	// rest are counted together. All the values are given if it is 0
+	TopN int `json:"topN,omitempty" db:"top_n" bson:"topN,omitempty"`
+	// Buckets - number of histogram buckets for range facets, DefaultBuckets
+	// is used if it is 0
+	Buckets int `json:"buckets,omitempty" db:"buckets" bson:"buckets,omitempty"`
+	// Search - options for Search filters on the field, pattern match is
+	// used if not given. Not exposed to clients
+	Search *TextSearch `json:"-" db:"-" bson:"-"`
+}
+
+// ColumnName - column in the store for this filter field
+func (fs *FilterSpec) ColumnName() string {
+	if fs.Column != "" {
+		return fs.Column
+	}
+	return fs.Field
 }
 
 //Matcher - matches the given fields. If multiple fileds are given the; the
@@ -50,6 +74,8 @@ type PropMatcher []any
 type Matcher struct {
 	Invert bool  `json:"invert" db:"invert" bson:"invert"`
 	Fields []any `json:"fields" db:"fields" bson:"fields"`
+	// All - for Lists, the list must have all the values instead of any
+	All bool `json:"all,omitempty" db:"all" bson:"all,omitempty"`
 }
 
 func (m *Matcher) IsValid() bool {
@@ -65,6 +91,11 @@ func (m *Matcher) IsValid() bool {
 type DateRangeMatcher struct {
 	DateRange
 	Invert bool `json:"invert" db:"invert" bson:"invert"`
+	// FromExcl, ToExcl - the bound was given as exclusive, such as with '>' in
+	// a filter query. From and To still hold the closest value within the
+	// bound, the flags are only used to format the filter as it was given
+	FromExcl bool `json:"fromExcl,omitempty" db:"from_excl" bson:"fromExcl,omitempty"`
+	ToExcl   bool `json:"toExcl,omitempty" db:"to_excl" bson:"toExcl,omitempty"`
 }
 
 func (dr *DateRangeMatcher) IsValid() bool {
@@ -74,6 +105,11 @@ func (dr *DateRangeMatcher) IsValid() bool {
 type RangeMatcher struct {
 	NumberRange
 	Invert bool `json:"invert" db:"invert" bson:"invert"`
+	// FromExcl, ToExcl - the bound was given as exclusive, such as with '>' in
+	// a filter query. From and To still hold the closest value within the
+	// bound, the flags are only used to format the filter as it was given
+	FromExcl bool `json:"fromExcl,omitempty" db:"from_excl" bson:"fromExcl,omitempty"`
+	ToExcl   bool `json:"toExcl,omitempty" db:"to_excl" bson:"toExcl,omitempty"`
 }
 
 func (r *RangeMatcher) IsValid() bool {
@@ -86,6 +122,12 @@ type CommonParams struct {
 	PageSize       int64   `json:"pageSize" db:"page_size" bson:"pageSize"`
 	Sort           string  `json:"sort" db:"sort" bson:"sort"`
 	SortDescending bool    `json:"sortDescending" db:"sort_desc" bson:"sortDescending"`
+	// Sorts - multiple sort fields in order of precedence, when given Sort
+	// and SortDescending are ignored. See SortFields
+	Sorts []SortField `json:"sorts,omitempty" db:"sorts" bson:"sorts,omitempty"`
+	// Cursor - opaque token from a previous page for keyset pagination, when
+	// given Page is ignored
+	Cursor string `json:"cursor,omitempty" db:"cursor" bson:"cursor,omitempty"`
 }
 
 func (qp *CommonParams) Offset() int64 {
@@ -51,7 +51,6 @@ func Wrap(cmd *cli.Command) *cli.Command {
 			EnvVars:  []string{"PG_PASS"},
 			Required: false,
 		},
-		// TODO - use this value in opts
 		&cli.StringFlag{
 			Name:     "pg-timezone",
 			Value:    "Asia/Kolkata",
@@ -59,6 +58,75 @@ func Wrap(cmd *cli.Command) *cli.Command {
 			EnvVars:  []string{"PG_TIMEZONE"},
 			Required: false,
 		},
+		&cli.StringFlag{
+			Name:     "pg-sslmode",
+			Value:    "disable",
+			Usage:    "SSL mode: disable, require, verify-ca or verify-full",
+			EnvVars:  []string{"PG_SSLMODE"},
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "pg-sslrootcert",
+			Usage:    "Path to the CA certificate used to verify the server",
+			EnvVars:  []string{"PG_SSLROOTCERT"},
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "pg-sslcert",
+			Usage:    "Path to the client certificate",
+			EnvVars:  []string{"PG_SSLCERT"},
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "pg-sslkey",
+			Usage:    "Path to the private key of the client certificate",
+			EnvVars:  []string{"PG_SSLKEY"},
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "pg-app-name",
+			Usage:    "Application name shown in pg_stat_activity",
+			EnvVars:  []string{"PG_APP_NAME"},
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "pg-statement-timeout",
+			Usage:    "Statements running longer than this are cancelled",
+			EnvVars:  []string{"PG_STATEMENT_TIMEOUT"},
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "pg-max-open-conns",
+			Usage:    "Maximum number of open connections in the pool",
+			EnvVars:  []string{"PG_MAX_OPEN_CONNS"},
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "pg-max-idle-conns",
+			Usage:    "Maximum number of idle connections in the pool",
+			EnvVars:  []string{"PG_MAX_IDLE_CONNS"},
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "pg-conn-max-lifetime",
+			Usage:    "Connections older than this are closed when idle",
+			EnvVars:  []string{"PG_CONN_MAX_LIFETIME"},
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name: "pg-trace",
+			Usage: "Log queries at trace level, slow queries as warnings " +
+				"and their plans at debug level",
+			EnvVars:  []string{"PG_TRACE"},
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "pg-slow-query",
+			Value:    DefaultSlowQuery,
+			Usage:    "Traced queries taking longer are logged as slow",
+			EnvVars:  []string{"PG_SLOW_QUERY"},
+			Required: false,
+		},
 	)
 
 	if cmd.Before == nil {
@@ -82,7 +150,7 @@ func RequirePostgres(ctx *cli.Context) error {
 	if urlStr != "" {
 		u, err := url.Parse(urlStr)
 		if err != nil {
-			return errx.Errf(err, "invalid URL '%s' given")
+			return errx.Errf(err, "invalid URL '%s' given", urlStr)
 		}
 
 		db, err := Connect(ctx.Context, u, ctx.String("pg-timezone"))
@@ -90,15 +158,31 @@ func RequirePostgres(ctx *cli.Context) error {
 			log.Fatal().Err(err).Msg("failed to connect to database")
 			return errx.Wrap(err)
 		}
+		// Rest of the options are expected to be in the URL
+		pool := &ConnOpts{
+			MaxOpenConns:    ctx.Int("pg-max-open-conns"),
+			MaxIdleConns:    ctx.Int("pg-max-idle-conns"),
+			ConnMaxLifetime: ctx.Duration("pg-conn-max-lifetime"),
+		}
+		pool.configure(db)
 		SetDefaultConn(db)
 	} else {
 		db, err := ConnectWithOpts(ctx.Context, &ConnOpts{
-			Host:     ctx.String("pg-host"),
-			Port:     ctx.Int("pg-port"),
-			User:     ctx.String("pg-user"),
-			DBName:   ctx.String("pg-db"),
-			Password: ctx.String("pg-pass"),
-			TimeZone: ctx.String("pg-timezone"),
+			Host:             ctx.String("pg-host"),
+			Port:             ctx.Int("pg-port"),
+			User:             ctx.String("pg-user"),
+			DBName:           ctx.String("pg-db"),
+			Password:         ctx.String("pg-pass"),
+			TimeZone:         ctx.String("pg-timezone"),
+			SSLMode:          ctx.String("pg-sslmode"),
+			SSLRootCert:      ctx.String("pg-sslrootcert"),
+			SSLCert:          ctx.String("pg-sslcert"),
+			SSLKey:           ctx.String("pg-sslkey"),
+			AppName:          ctx.String("pg-app-name"),
+			StatementTimeout: ctx.Duration("pg-statement-timeout"),
+			MaxOpenConns:     ctx.Int("pg-max-open-conns"),
+			MaxIdleConns:     ctx.Int("pg-max-idle-conns"),
+			ConnMaxLifetime:  ctx.Duration("pg-conn-max-lifetime"),
 		})
 		if err != nil {
 			log.Fatal().Err(err).Msg("failed to connect to database")
@@ -109,5 +193,11 @@ func RequirePostgres(ctx *cli.Context) error {
 	if err := defDB.Ping(); err != nil {
 		log.Fatal().Err(err).Msg("failed to ping database")
 	}
+	if ctx.Bool("pg-trace") {
+		tracer := NewTracer()
+		tracer.SlowThreshold = ctx.Duration("pg-slow-query")
+		tracer.Explain = true
+		SetTracer(tracer)
+	}
 	return nil
 }
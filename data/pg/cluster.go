@@ -0,0 +1,125 @@
+package pg
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// Cluster - a primary database with read replicas. Writes go to the primary,
+// reads are spread over the replicas in round robin order. Replicas lag
+// behind the primary, use UsePrimary for reads that must see the latest
+// writes
+type Cluster struct {
+	primary  *sqlx.DB
+	replicas []*sqlx.DB
+	next     atomic.Uint64
+}
+
+// NewCluster - cluster with given primary and replicas, reads go to the
+// primary if there are no replicas
+func NewCluster(primary *sqlx.DB, replicas ...*sqlx.DB) *Cluster {
+	return &Cluster{
+		primary:  primary,
+		replicas: replicas,
+	}
+}
+
+// ConnectCluster - connects to the primary and the replicas with given
+// options. Options used for the primary are used by Listen
+func ConnectCluster(
+	gtx context.Context,
+	primary *ConnOpts,
+	replicas ...*ConnOpts) (*Cluster, error) {
+	dbs := make([]*sqlx.DB, 0, len(replicas))
+	for _, opts := range replicas {
+		db, err := ConnectWithOpts(gtx, opts)
+		if err != nil {
+			closeAll(dbs)
+			return nil, errx.Errf(err, "failed to connect to replica at '%s'",
+				opts.Host)
+		}
+		dbs = append(dbs, db)
+	}
+
+	db, err := ConnectWithOpts(gtx, primary)
+	if err != nil {
+		closeAll(dbs)
+		return nil, errx.Errf(err, "failed to connect to primary at '%s'",
+			primary.Host)
+	}
+	return NewCluster(db, dbs...), nil
+}
+
+func closeAll(dbs []*sqlx.DB) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
+
+// Primary - the primary database
+func (cl *Cluster) Primary() *sqlx.DB {
+	return cl.primary
+}
+
+// Replica - next replica in round robin order, the primary if there are no
+// replicas
+func (cl *Cluster) Replica() *sqlx.DB {
+	if len(cl.replicas) == 0 {
+		return cl.primary
+	}
+	idx := cl.next.Add(1) % uint64(len(cl.replicas))
+	return cl.replicas[idx]
+}
+
+// Reader - querier for reads. It is the transaction carried by the context
+// if there is one on the primary, the primary if the context is marked with
+// UsePrimary and a replica otherwise
+func (cl *Cluster) Reader(gtx context.Context) Querier {
+	if st, ok := gtx.Value(txKey{}).(*txState); ok && st.db == cl.primary {
+		return traced(st.tx)
+	}
+	if usePrimary, _ := gtx.Value(primaryKey{}).(bool); usePrimary {
+		return traced(cl.primary)
+	}
+	return traced(cl.Replica())
+}
+
+// Writer - querier for writes, it is the transaction carried by the context
+// if there is one on the primary and the primary otherwise
+func (cl *Cluster) Writer(gtx context.Context) Querier {
+	return ConnFor(gtx, cl.primary)
+}
+
+// Close - closes the connections to the primary and the replicas
+func (cl *Cluster) Close() error {
+	closeAll(cl.replicas)
+	return cl.primary.Close()
+}
+
+type primaryKey struct{}
+
+// UsePrimary - marks the context so that reads through clusters using it go
+// to the primary, such as for reading rows that were just written
+func UsePrimary(gtx context.Context) context.Context {
+	return context.WithValue(gtx, primaryKey{}, true)
+}
+
+// NewClusterGetterDeleter - getter-deleter that reads from the replicas of
+// the cluster and deletes on the primary. Specs are used like in
+// NewGetterDeleterWithSpecs, no field is allowed for data types without specs
+func NewClusterGetterDeleter(
+	cl *Cluster, specs map[string][]*data.FilterSpec) data.GetterDeleter {
+	return &getterDeleter{specs: specs, cluster: cl}
+}
+
+// NewClusterRepository - repository for T that reads from the replicas of
+// the cluster and writes to the primary, see NewRepository
+func NewClusterRepository[T any](cl *Cluster) data.Repository[T] {
+	repo := newRepository[T](cl.primary)
+	repo.cluster = cl
+	return repo
+}
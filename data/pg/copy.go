@@ -0,0 +1,103 @@
+package pg
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// RowIter - gives rows one by one to yield until it returns false, it has
+// the same shape as iter.Seq
+type RowIter[T any] func(yield func(row *T) bool)
+
+// RowsOf - iterator over the rows in the slice
+func RowsOf[T any](rows []T) RowIter[T] {
+	return func(yield func(row *T) bool) {
+		for idx := range rows {
+			if !yield(&rows[idx]) {
+				return
+			}
+		}
+	}
+}
+
+// CopyFrom - loads the rows into the table using COPY, which is much faster
+// than inserts for large number of rows. Columns are taken from 'db' tags of
+// T like for repositories, keys generated by the database are left out and
+// are not set in the rows. The table can be qualified with schema, T's table
+// is used if it is empty. Runs in the transaction carried by the context if
+// there is one, otherwise in a new transaction on the default connection.
+// Returns number of rows copied
+func CopyFrom[T any](
+	gtx context.Context, table string, rows RowIter[T]) (int64, error) {
+	info, err := data.NewModelInfo[T]("db")
+	if err != nil {
+		return 0, err
+	}
+	if table == "" {
+		table = info.Name
+	}
+	var probe T
+	cols, _ := info.Values(&probe, false)
+
+	count := int64(0)
+	// Rows can not be iterated again, hence no retries
+	opts := &TxOpts{MaxRetries: -1}
+	err = WithTx(gtx, opts, func(gtx context.Context) error {
+		count = 0
+		stmt, err := TxFrom(gtx).PrepareContext(gtx, copyIn(table, cols))
+		if err != nil {
+			return errx.Errf(err, "failed to start copy into '%s'", table)
+		}
+		defer stmt.Close()
+
+		var rowErr error
+		rows(func(row *T) bool {
+			_, vals := info.Values(row, false)
+			for idx, val := range vals {
+				vals[idx] = copyValue(val)
+			}
+			if _, rowErr = stmt.ExecContext(gtx, vals...); rowErr != nil {
+				return false
+			}
+			count++
+			return true
+		})
+		if rowErr != nil {
+			return errx.Errf(rowErr, "failed to copy row %d into '%s'",
+				count+1, table)
+		}
+		if _, err := stmt.ExecContext(gtx); err != nil {
+			return errx.Errf(err, "failed to copy into '%s'", table)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func copyIn(table string, cols []string) string {
+	if schema, name, found := strings.Cut(table, "."); found {
+		return pq.CopyInSchema(schema, name, cols...)
+	}
+	return pq.CopyIn(table, cols...)
+}
+
+// copyValue - slices that are not driver values are sent as arrays
+func copyValue(val any) any {
+	if _, ok := val.(driver.Valuer); ok {
+		return val
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		return pq.Array(val)
+	}
+	return val
+}
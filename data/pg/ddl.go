@@ -0,0 +1,416 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"go/format"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// colSpec - column of a model as described by its 'db' and 'pg' tags
+type colSpec struct {
+	name     string
+	typ      string
+	def      string
+	pk       bool
+	auto     bool
+	index    bool
+	unique   bool
+	nullable bool
+}
+
+// modelColumns - columns of the model, see TableSQL
+func modelColumns(mi *data.ModelInfo) ([]*colSpec, error) {
+	cols := make([]*colSpec, 0, len(mi.Columns))
+	for _, col := range mi.Columns {
+		sf := mi.Field(col)
+		cs := &colSpec{name: col.Name, pk: col.Key, auto: col.Auto}
+		typ, nullable, typErr := sqlType(sf.Type)
+
+		for _, opt := range splitTagOpts(sf.Tag.Get("pg")) {
+			key, val, _ := strings.Cut(opt, "=")
+			switch strings.TrimSpace(key) {
+			case "type":
+				typ, typErr = strings.TrimSpace(val), nil
+			case "default":
+				cs.def = strings.TrimSpace(val)
+			case "pk":
+				cs.pk = true
+			case "auto":
+				cs.auto = true
+			case "index":
+				cs.index = true
+			case "unique":
+				cs.unique = true
+			case "null":
+				nullable = true
+			case "notnull":
+				nullable = false
+			case "":
+			default:
+				return nil, errx.Fmt("invalid option '%s' in pg tag of '%s.%s'",
+					opt, mi.Name, col.Name)
+			}
+		}
+		if typErr != nil || typ == "" {
+			return nil, errx.Errf(typErr,
+				"no column type for '%s.%s', give it with pg:\"type=...\"",
+				mi.Name, col.Name)
+		}
+		cs.typ = typ
+		cs.nullable = nullable && !cs.pk
+		cols = append(cols, cs)
+	}
+	return cols, nil
+}
+
+// splitTagOpts - splits the tag on commas that are not in parentheses or
+// quotes, so that types such as NUMERIC(10,2) can be given
+func splitTagOpts(tag string) []string {
+	opts := make([]string, 0, 4)
+	depth, quoted, start := 0, false, 0
+	for idx, r := range tag {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			opts = append(opts, tag[start:idx])
+			start = idx + 1
+		}
+	}
+	if start < len(tag) {
+		opts = append(opts, tag[start:])
+	}
+	return opts
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	durType     = reflect.TypeOf(time.Duration(0))
+	rawJsonType = reflect.TypeOf(json.RawMessage{})
+)
+
+// sqlType - column type for the go type. Pointers and types like
+// sql.NullString are nullable
+func sqlType(rt reflect.Type) (string, bool, error) {
+	switch rt {
+	case timeType:
+		return "TIMESTAMPTZ", false, nil
+	case durType:
+		return "BIGINT", false, nil
+	case rawJsonType:
+		return "JSONB", true, nil
+	}
+
+	switch rt.Kind() {
+	case reflect.Pointer:
+		typ, _, err := sqlType(rt.Elem())
+		return typ, true, err
+	case reflect.String:
+		return "TEXT", false, nil
+	case reflect.Bool:
+		return "BOOLEAN", false, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64,
+		reflect.Uint32:
+		return "BIGINT", false, nil
+	case reflect.Int32, reflect.Uint16:
+		return "INTEGER", false, nil
+	case reflect.Int16, reflect.Int8, reflect.Uint8:
+		return "SMALLINT", false, nil
+	case reflect.Float32:
+		return "REAL", false, nil
+	case reflect.Float64:
+		return "DOUBLE PRECISION", false, nil
+	case reflect.Slice, reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			return "BYTEA", true, nil
+		}
+		typ, _, err := sqlType(rt.Elem())
+		if err != nil || typ == "JSONB" {
+			return "JSONB", true, nil
+		}
+		return typ + "[]", true, nil
+	case reflect.Struct:
+		// sql.NullString, sql.Null[T] and the like
+		if vf, ok := rt.FieldByName("Valid"); ok && rt.NumField() == 2 &&
+			vf.Type.Kind() == reflect.Bool {
+			typ, _, err := sqlType(rt.Field(1 - vf.Index[0]).Type)
+			return typ, true, err
+		}
+		return "JSONB", false, nil
+	case reflect.Map, reflect.Interface:
+		return "JSONB", true, nil
+	}
+	return "", false, errx.Fmt("no column type for go type '%s'", rt)
+}
+
+// TableSQL - CREATE TABLE statement for the model followed by CREATE INDEX
+// statements. Column names and the key are taken from 'db' tags as described
+// in data.NewModelInfo, types are inferred from the field types. Options in
+// 'pg' tags override or add to those:
+//
+//	type Item struct {
+//		_     struct{}  `db:"item"`
+//		Id    int       `db:"id,key,auto" pg:"type=INT"`
+//		Code  string    `db:"code" pg:"type=VARCHAR(20),unique"`
+//		Price float64   `db:"price" pg:"type=NUMERIC(10,2),index"`
+//		Tags  []string  `db:"tags" pg:"default='{}'"`
+//		Note  *string   `db:"note"`
+//	}
+//
+// Options are type=<sql type>, default=<sql expression>, pk for a column of
+// composite primary key, auto for a value generated by the database, index,
+// unique, null and notnull. Pointers, slices, maps and sql.Null* types are
+// nullable, rest are NOT NULL. Auto keys without default are identity
+// columns
+func TableSQL(mi *data.ModelInfo) (string, error) {
+	cols, err := modelColumns(mi)
+	if err != nil {
+		return "", err
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("CREATE TABLE IF NOT EXISTS " + QuoteIdent(mi.Name) + " (")
+	pk := make([]string, 0, 1)
+	for idx, col := range cols {
+		buf.WriteString(data.Qop(idx == 0, "\n\t", ",\n\t"))
+		buf.WriteString(pq.QuoteIdentifier(col.name) + " " + col.typ)
+		if !col.nullable {
+			buf.WriteString(" NOT NULL")
+		}
+		switch {
+		case col.def != "":
+			buf.WriteString(" DEFAULT " + col.def)
+		case col.auto:
+			buf.WriteString(" GENERATED ALWAYS AS IDENTITY")
+		}
+		if col.pk {
+			pk = append(pk, pq.QuoteIdentifier(col.name))
+		}
+	}
+	if len(pk) != 0 {
+		buf.WriteString(",\n\tPRIMARY KEY (" + strings.Join(pk, ", ") + ")")
+	}
+	buf.WriteString("\n);\n")
+
+	prefix := strings.ReplaceAll(mi.Name, ".", "_") + "_"
+	for _, col := range cols {
+		if !col.index && !col.unique {
+			continue
+		}
+		name := prefix + col.name + data.Qop(col.unique, "_key", "_idx")
+		buf.WriteString(data.Qop(col.unique,
+			"CREATE UNIQUE INDEX IF NOT EXISTS ",
+			"CREATE INDEX IF NOT EXISTS "))
+		buf.WriteString(pq.QuoteIdentifier(name) + " ON " +
+			QuoteIdent(mi.Name) + " (" + pq.QuoteIdentifier(col.name) + ");\n")
+	}
+	return buf.String(), nil
+}
+
+// CreateTable - creates the table and indexes for the model if they do not
+// exist, see TableSQL
+func CreateTable(gtx context.Context, mi *data.ModelInfo) error {
+	query, err := TableSQL(mi)
+	if err != nil {
+		return err
+	}
+	if _, err := ConnFor(gtx, nil).ExecContext(gtx, query); err != nil {
+		return errx.Errf(err, "failed to create table '%s'", mi.Name)
+	}
+	return nil
+}
+
+// StructSource - Go source of a struct for the table, with 'db' and 'pg'
+// tags that TableSQL turns back into the table. Defaults are carried over
+// as given by the database. If typeName is empty, it is derived from the
+// table name
+func StructSource(ti *TableInfo, pkg, typeName string) (string, error) {
+	table := ti.Name
+	if ti.Schema != "" && ti.Schema != "public" {
+		table = ti.Schema + "." + ti.Name
+	}
+	if typeName == "" {
+		typeName = goName(ti.Name)
+	}
+
+	pk := ti.PrimaryKey()
+	imports := map[string]bool{}
+	buf := strings.Builder{}
+	buf.WriteString("// " + typeName + " - row of " + table + "\n")
+	buf.WriteString("type " + typeName + " struct {\n")
+	buf.WriteString("\t_ struct{} `db:" + strconv.Quote(table) + "`\n")
+
+	for _, col := range ti.Columns {
+		goType, pkgs := goTypeOf(col.Type, col.Nullable)
+		for _, p := range pkgs {
+			imports[p] = true
+		}
+
+		dbTag := col.Name
+		pgOpts := []string{"type=" + col.Type}
+		isPk := slices.Contains(pk, col.Name)
+		switch {
+		case isPk && pk[0] == col.Name:
+			dbTag += ",key"
+		case isPk:
+			pgOpts = append(pgOpts, "pk")
+		}
+		if col.Identity {
+			dbTag += ",auto"
+		} else if col.Default != nil && !strings.Contains(*col.Default, "`") {
+			pgOpts = append(pgOpts, "default="+*col.Default)
+		}
+		if col.Nullable {
+			pgOpts = append(pgOpts, "null")
+		}
+		if idx := ti.Index(col.Name); idx != nil {
+			pgOpts = append(pgOpts, data.Qop(idx.Unique, "unique", "index"))
+		}
+
+		name := goName(col.Name)
+		buf.WriteString("\t" + name + " " + goType + " `json:" +
+			strconv.Quote(jsonName(name)) + " db:" + strconv.Quote(dbTag) +
+			" pg:" + strconv.Quote(strings.Join(pgOpts, ",")) + "`\n")
+	}
+	buf.WriteString("}\n")
+
+	head := strings.Builder{}
+	head.WriteString("package " + pkg + "\n\n")
+	if len(imports) != 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			iStd := !strings.Contains(strings.Split(paths[i], "/")[0], ".")
+			jStd := !strings.Contains(strings.Split(paths[j], "/")[0], ".")
+			if iStd != jStd {
+				return iStd
+			}
+			return paths[i] < paths[j]
+		})
+		head.WriteString("import (\n")
+		thirdParty := false
+		for _, path := range paths {
+			// Standard library packages come first, separated from others
+			first, _, _ := strings.Cut(path, "/")
+			if strings.Contains(first, ".") && !thirdParty {
+				thirdParty = true
+				head.WriteString(data.Qop(path == paths[0], "", "\n"))
+			}
+			head.WriteString("\t" + strconv.Quote(path) + "\n")
+		}
+		head.WriteString(")\n\n")
+	}
+
+	src, err := format.Source([]byte(head.String() + buf.String()))
+	if err != nil {
+		return "", errx.Errf(err, "failed to format struct for '%s'", table)
+	}
+	return string(src), nil
+}
+
+// goTypeOf - go type for the column type along with the packages it needs.
+// Nullable columns get pointers, except for types where nil is NULL
+func goTypeOf(colType string, nullable bool) (string, []string) {
+	typ := canonicalType(colType)
+	if start := strings.Index(typ, "("); start != -1 {
+		end := strings.Index(typ, ")")
+		typ = typ[:start] + typ[end+1:]
+	}
+
+	if elem, isArray := strings.CutSuffix(typ, "[]"); isArray {
+		vec := "string"
+		switch baseGoType(elem) {
+		case "int", "int16", "int64":
+			vec = "int64"
+		case "float32", "float64":
+			vec = "float64"
+		case "bool":
+			vec = "bool"
+		case "time.Time":
+			vec = "time.Time"
+		}
+		pkgs := []string{"github.com/varunamachi/libx/data"}
+		if vec == "time.Time" {
+			pkgs = append(pkgs, "time")
+		}
+		return "data.Vec[" + vec + "]", pkgs
+	}
+
+	goType := baseGoType(typ)
+	pkgs := []string{}
+	switch goType {
+	case "time.Time":
+		pkgs = append(pkgs, "time")
+	case "json.RawMessage":
+		return goType, []string{"encoding/json"}
+	case "[]byte":
+		return goType, nil
+	}
+	return data.Qop(nullable, "*", "") + goType, pkgs
+}
+
+func baseGoType(typ string) string {
+	switch typ {
+	case "smallint":
+		return "int16"
+	case "integer":
+		return "int"
+	case "bigint":
+		return "int64"
+	case "real":
+		return "float32"
+	case "double precision", "numeric":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "date", "timestamp with time zone", "timestamp without time zone":
+		return "time.Time"
+	case "json", "jsonb":
+		return "json.RawMessage"
+	case "bytea":
+		return "[]byte"
+	}
+	return "string"
+}
+
+// goName - exported go name for the snake cased name
+func goName(name string) string {
+	buf := strings.Builder{}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		buf.WriteString(string(runes))
+	}
+	out := buf.String()
+	if out == "" || unicode.IsDigit([]rune(out)[0]) {
+		out = "F" + out
+	}
+	return out
+}
+
+// jsonName - go name with first letter in lower case
+func jsonName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -2,136 +2,235 @@ package pg
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
 
-	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
 	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
 )
 
+// GetFilterValues - values and facet counts for the given filter specs,
+// computed in a single query using the default connection, or the
+// transaction carried by the context. Facet of a field
+// is computed with the filter excluding the field's own condition. Elements
+// of array fields are counted individually. If TopN is given for a spec,
+// only those many values are given and rest are counted in Facet.Other.
+// Range fields get extremes and a histogram with spec.NumBuckets() buckets
 func GetFilterValues(
 	gtx context.Context,
 	dtype string,
 	specs []*data.FilterSpec,
 	filter *data.Filter) (*data.FilterValues, error) {
+	return filterValues(gtx, ConnFor(gtx, nil), dtype, specs, filter)
+}
 
-	fvals := data.NewFilterValues()
-	sel := NewSelectorGenerator().Selector(filter)
-	for _, spec := range specs {
-		switch spec.Type {
-		case data.FtProp:
-			fallthrough
-		case data.FtArray:
-			vals, err := getValues(gtx, dtype, spec, sel)
-			if err != nil {
-				return nil, err
-			}
-			fvals.Values[spec.Field] = vals
-		case data.FtDateRange:
-			dts, err := getDateRangeExtremes(gtx, dtype, spec, sel)
-			if err != nil {
-				return nil, err
-			}
-			fvals.Dates[spec.Field] = dts
-		case data.FtNumRange:
-			rg, err := getRangeExtremes(gtx, dtype, spec, sel)
-			if err != nil {
-				return nil, err
-			}
-			fvals.Ranges[spec.Field] = rg
-
-		}
-	}
+// facetRow - a value with count, a histogram bucket or the count of values
+// other than the top ones for a facet
+type facetRow struct {
+	Facet int             `db:"facet"`
+	Ord   int64           `db:"ord"`
+	Value []byte          `db:"value"`
+	Count int64           `db:"cnt"`
+	Lo    sql.NullFloat64 `db:"lo"`
+	Hi    sql.NullFloat64 `db:"hi"`
+	Other bool            `db:"other"`
+}
 
-	return fvals, nil
+type histogram struct {
+	lo, hi sql.NullFloat64
+	counts []int64
 }
 
-func getValues(
+func filterValues(
 	gtx context.Context,
+	db sqlx.QueryerContext,
 	dtype string,
-	spec *data.FilterSpec,
-	sel Selector) ([]interface{}, error) {
-
-	sq := squirrel.StatementBuilder.
-		Select(spec.Field).
-		Distinct().
-		From(dtype)
-	if !sel.IsEmpty() {
-		sq.Where(sel.QueryFragment, sel.Args...)
-	}
-	query, args, err := sq.ToSql()
+	specs []*data.FilterSpec,
+	filter *data.Filter) (*data.FilterValues, error) {
 
+	fvals := data.NewFilterValues()
+	query, args, facets, err := facetQuery(dtype, specs, filter)
 	if err != nil {
-		return nil, errx.Errf(err, "failed to build sql query")
+		return nil, err
+	}
+	if len(facets) == 0 {
+		return fvals, nil
 	}
 
-	// query := "SELECT DISTINCT %s FROM %s"
-	// if !sel.IsEmpty() {
-	// 	query += " WHERE " + sel.QueryFragment
-	// }
-	// query += " ORDER BY %s"
-	// query = fmt.Sprintf(query, spec.Field, dtype, spec.Field)
+	hists := make([]*histogram, len(facets))
+	for idx, spec := range facets {
+		fvals.Facets[spec.Field] = &data.Facet{}
+		switch spec.Type {
+		case data.FtProp, data.FtArray:
+			fvals.Values[spec.Field] = []any{}
+		default:
+			hists[idx] = &histogram{
+				counts: make([]int64, spec.NumBuckets()),
+			}
+		}
+	}
 
-	out := make([]interface{}, 0, 100)
-	err = Conn().SelectContext(gtx, &out, query, args...)
+	rows, err := db.QueryxContext(gtx, query, args...)
 	if err != nil {
 		return nil, errx.Errf(err,
-			"failed to get distinct values for '%s' in '%s'", spec.Field, dtype)
+			"failed to get filter values for '%s'", dtype)
 	}
-	return out, nil
-}
+	defer rows.Close()
 
-func getDateRangeExtremes(
-	gtx context.Context,
-	dtype string,
-	spec *data.FilterSpec,
-	sel Selector) (*data.DateRange, error) {
-
-	sq := squirrel.StatementBuilder.Select(
-		"min(\""+spec.Field+"\") AS _from",
-		"max(\""+spec.Field+"\") AS _to",
-	).From(dtype)
-	if !sel.IsEmpty() {
-		sq.Where(sel.QueryFragment, sel.Args...)
-	}
+	for rows.Next() {
+		var row facetRow
+		if err := rows.StructScan(&row); err != nil {
+			return nil, errx.Errf(err,
+				"failed to read filter values for '%s'", dtype)
+		}
+		spec := facets[row.Facet]
+		facet := fvals.Facets[spec.Field]
 
-	query, args, err := sq.ToSql()
-	if err != nil {
-		return nil, errx.Errf(err, "failed to build sql query")
+		if hist := hists[row.Facet]; hist != nil {
+			hist.lo, hist.hi = row.Lo, row.Hi
+			if row.Ord >= 1 && int(row.Ord) <= len(hist.counts) {
+				hist.counts[row.Ord-1] = row.Count
+			}
+			continue
+		}
+		if row.Other {
+			facet.Other = row.Count
+			continue
+		}
+		var val any
+		if err := json.Unmarshal(row.Value, &val); err != nil {
+			return nil, errx.Errf(err,
+				"failed to decode value of '%s'", spec.Field)
+		}
+		facet.Values = append(facet.Values,
+			data.FacetValue{Value: val, Count: row.Count})
+		fvals.Values[spec.Field] = append(fvals.Values[spec.Field], val)
 	}
-
-	out := data.DateRange{}
-	err = Conn().GetContext(gtx, &out, query, args...)
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, errx.Errf(err,
-			"failed to get date range for '%s' in '%s'", spec.Field, dtype)
+			"failed to read filter values for '%s'", dtype)
+	}
+
+	for idx, hist := range hists {
+		if hist == nil || !hist.lo.Valid || !hist.hi.Valid {
+			continue
+		}
+		spec := facets[idx]
+		lo, hi := hist.lo.Float64, hist.hi.Float64
+		if spec.Type == data.FtDateRange {
+			fvals.Facets[spec.Field].Buckets = data.NewBuckets(
+				lo, hi, hist.counts, data.FromEpoch)
+			fvals.Dates[spec.Field] = &data.DateRange{
+				From: data.EpochTime(lo),
+				To:   data.EpochTime(hi),
+			}
+			continue
+		}
+		fvals.Facets[spec.Field].Buckets = data.NewBuckets(
+			lo, hi, hist.counts, data.AsNumber)
+		fvals.Ranges[spec.Field] = &data.NumberRange{From: lo, To: hi}
 	}
-	return &out, nil
+	return fvals, nil
 }
 
-func getRangeExtremes(
-	gtx context.Context,
+// facetQuery - single query giving facets of all the specs. Each facet is
+// computed in a CTE and the results are combined with UNION ALL. Gives the
+// specs for which facets are computed, row's facet is index into it
+func facetQuery(
 	dtype string,
-	spec *data.FilterSpec,
-	sel Selector) (*data.NumberRange, error) {
-
-	sq := squirrel.StatementBuilder.Select(
-		"min(\""+spec.Field+"\") as _from",
-		"max(\""+spec.Field+"\") as _to",
-	).From(dtype)
-	if !sel.IsEmpty() {
-		sq.Where(sel.QueryFragment, sel.Args...)
+	specs []*data.FilterSpec,
+	filter *data.Filter) (string, []any, []*data.FilterSpec, error) {
+
+	gen := NewSelectorGenerator(specs...)
+	ctes := make([]string, 0, len(specs)*2)
+	parts := make([]string, 0, len(specs)*2)
+	facets := make([]*data.FilterSpec, 0, len(specs))
+
+	for _, spec := range specs {
+		switch spec.Type {
+		case data.FtProp, data.FtArray, data.FtDateRange, data.FtNumRange:
+		default:
+			continue
+		}
+
+		conds, err := gen.Conds(filter.Without(spec.Field))
+		if err != nil {
+			return "", nil, nil, err
+		}
+		col := QuoteIdent(spec.ColumnName())
+		idx := strconv.Itoa(len(facets))
+		name := "_f" + idx
+		facets = append(facets, spec)
+
+		if spec.Type == data.FtProp || spec.Type == data.FtArray {
+			val, from := col, dtype
+			if spec.Type == data.FtArray {
+				val = "_u._fv"
+				from += " CROSS JOIN LATERAL unnest(" + col + ") AS _u(_fv)"
+			}
+			ctes = append(ctes, name+" AS ("+
+				"SELECT _fv, cnt, "+
+				"row_number() OVER (ORDER BY cnt DESC, _fv) AS rn "+
+				"FROM (SELECT "+val+" AS _fv, count(*) AS cnt "+
+				"FROM "+from+whereClause(conds, val+" IS NOT NULL")+
+				" GROUP BY 1) AS _g)")
+
+			topCond, topN := "", strconv.Itoa(spec.TopN)
+			if spec.TopN > 0 {
+				topCond = " WHERE rn <= " + topN
+			}
+			parts = append(parts, "SELECT "+idx+" AS facet, rn AS ord, "+
+				"to_jsonb(_fv) AS value, cnt, NULL::float8 AS lo, "+
+				"NULL::float8 AS hi, false AS other FROM "+name+topCond)
+			if spec.TopN > 0 {
+				parts = append(parts, "SELECT "+idx+" AS facet, "+
+					"0::bigint AS ord, NULL::jsonb AS value, "+
+					"sum(cnt)::bigint AS cnt, NULL::float8 AS lo, "+
+					"NULL::float8 AS hi, true AS other FROM "+name+
+					" WHERE rn > "+topN+" HAVING count(*) > 0")
+			}
+			continue
+		}
+
+		val := col + "::float8"
+		if spec.Type == data.FtDateRange {
+			val = "extract(epoch FROM " + col + ")::float8"
+		}
+		num := strconv.Itoa(spec.NumBuckets())
+		ctes = append(ctes,
+			name+" AS (SELECT "+val+" AS _fv FROM "+dtype+
+				whereClause(conds, col+" IS NOT NULL")+")",
+			name+"b AS (SELECT min(_fv) AS _lo, max(_fv) AS _hi "+
+				"FROM "+name+")")
+		parts = append(parts, "SELECT "+idx+" AS facet, "+
+			"(CASE WHEN _hi = _lo THEN 1 ELSE "+
+			"least(width_bucket(_fv, _lo, _hi, "+num+"), "+num+") "+
+			"END)::bigint AS ord, NULL::jsonb AS value, count(*) AS cnt, "+
+			"_lo AS lo, _hi AS hi, false AS other FROM "+name+", "+name+
+			"b GROUP BY 2, 5, 6")
 	}
 
-	query, args, err := sq.ToSql()
-	if err != nil {
-		return nil, errx.Errf(err, "failed to build sql query")
+	if len(facets) == 0 {
+		return "", nil, nil, nil
 	}
+	query := "WITH " + strings.Join(ctes, ", ") + " " +
+		strings.Join(parts, " UNION ALL ") +
+		" ORDER BY facet, other, ord"
+	return query, gen.Args(), facets, nil
+}
 
-	out := data.NumberRange{}
-	err = Conn().GetContext(gtx, &out, query, args...)
-	if err != nil {
-		return nil, errx.Errf(err,
-			"failed to get number range for '%s' in '%s'", spec.Field, dtype)
+func whereClause(conds ...string) string {
+	nonEmpty := make([]string, 0, len(conds))
+	for _, cond := range conds {
+		if cond != "" {
+			nonEmpty = append(nonEmpty, cond)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
 	}
-	return &out, nil
+	return " WHERE " + strings.Join(nonEmpty, " AND ")
 }
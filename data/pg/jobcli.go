@@ -0,0 +1,152 @@
+package pg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+	"github.com/varunamachi/libx/iox"
+)
+
+// JobCommands - commands for managing jobs in the jobs table
+func JobCommands() *cli.Command {
+	return Wrap(&cli.Command{
+		Name:        "jobs",
+		Description: "Commands for managing background jobs",
+		Subcommands: []*cli.Command{
+			setupJobsCmd(),
+			listJobsCmd(),
+			retryJobsCmd(),
+			purgeJobsCmd(),
+		},
+	})
+}
+
+func setupJobsCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "setup",
+		Description: "Creates the jobs table if it does not exist",
+		Action: func(ctx *cli.Context) error {
+			return CreateJobsTable(ctx.Context)
+		},
+	}
+}
+
+func listJobsCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "list",
+		Description: "Lists jobs matching the filter",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Filter query, for example 'status:dead kind:mail'",
+			},
+			&cli.StringFlag{
+				Name:  "sort",
+				Usage: "Sort fields, for example '-updated,id'",
+			},
+			&cli.Int64Flag{
+				Name:  "page",
+				Value: 0,
+				Usage: "Page number, starting from 0",
+			},
+			&cli.Int64Flag{
+				Name:  "page-size",
+				Value: 50,
+				Usage: "Number of jobs in a page",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.Int64("page") < 0 || ctx.Int64("page-size") < 0 {
+				return errx.Fmt("page and page-size should not be negative")
+			}
+			params := &data.CommonParams{
+				Page:     ctx.Int64("page"),
+				PageSize: ctx.Int64("page-size"),
+			}
+			if q := ctx.String("filter"); q != "" {
+				filter, err := data.ParseFilter(q, jobSpecs...)
+				if err != nil {
+					return err
+				}
+				params.Filter = filter
+			}
+			if s := ctx.String("sort"); s != "" {
+				sorts, err := data.ParseSorts(s)
+				if err != nil {
+					return err
+				}
+				params.Sorts = sorts
+			}
+
+			jobs, err := GetJobs(ctx.Context, params)
+			if err != nil {
+				return err
+			}
+			iox.PrintJSON(jobs)
+			return nil
+		},
+	}
+}
+
+func retryJobsCmd() *cli.Command {
+	return &cli.Command{
+		Name: "retry",
+		Description: "Makes the dead or pending jobs with given ids run " +
+			"now, all dead jobs are retried if no ids are given",
+		Flags: []cli.Flag{
+			&cli.Int64SliceFlag{
+				Name:  "id",
+				Usage: "Id of the job to retry, can be repeated",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			count, err := RetryJobs(ctx.Context, ctx.Int64Slice("id")...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d jobs will be retried\n", count)
+			return nil
+		},
+	}
+}
+
+func purgeJobsCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "purge",
+		Description: "Deletes finished jobs that were updated a while ago",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "status",
+				Value: cli.NewStringSlice(string(JobDone)),
+				Usage: "Status of the jobs to delete, 'done' or 'dead'",
+			},
+			&cli.DurationFlag{
+				Name:  "older-than",
+				Value: 7 * 24 * time.Hour,
+				Usage: "Delete jobs last updated before this duration",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			statuses := make([]JobStatus, 0, 2)
+			for _, status := range ctx.StringSlice("status") {
+				switch JobStatus(status) {
+				case JobDone, JobDead:
+					statuses = append(statuses, JobStatus(status))
+				default:
+					return errx.Fmt("invalid job status '%s'", status)
+				}
+			}
+
+			count, err := PurgeJobs(
+				ctx.Context, ctx.Duration("older-than"), statuses...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d jobs deleted\n", count)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,538 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// JobsTable - table that holds the jobs, see CreateJobsTable
+const JobsTable = "libx_job"
+
+// JobsChannel - channel on which workers are notified of new jobs
+const JobsChannel = "libx_jobs"
+
+// DefaultMaxAttempts - number of times a job is tried before it is marked
+// dead, if not given when the job is enqueued
+const DefaultMaxAttempts = 5
+
+var ErrDuplicateJob = errx.Declare(
+	"pg.job.duplicate", http.StatusConflict,
+	"Duplicate Job", "a job with same unique key is already queued")
+
+// JobStatus - state of a job
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobDead    JobStatus = "dead"
+)
+
+const jobsSchema = `
+CREATE TABLE IF NOT EXISTS libx_job (
+	id				BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+	kind			TEXT NOT NULL,
+	payload			JSONB NOT NULL DEFAULT 'null',
+	status			TEXT NOT NULL DEFAULT 'pending',
+	priority		INT NOT NULL DEFAULT 0,
+	run_at			TIMESTAMPTZ NOT NULL DEFAULT now(),
+	attempts		INT NOT NULL DEFAULT 0,
+	max_attempts	INT NOT NULL DEFAULT 5,
+	unique_key		TEXT,
+	last_error		TEXT,
+	locked_by		TEXT,
+	locked_until	TIMESTAMPTZ,
+	created			TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated			TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS libx_job_due
+	ON libx_job (priority DESC, run_at, id)
+	WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS libx_job_lease
+	ON libx_job (locked_until)
+	WHERE status = 'running';
+
+CREATE UNIQUE INDEX IF NOT EXISTS libx_job_unique
+	ON libx_job (kind, unique_key)
+	WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
+`
+
+const jobCols = `id, kind, payload, status, priority, run_at, attempts, ` +
+	`max_attempts, unique_key, last_error, locked_by, locked_until, ` +
+	`created, updated`
+
+// Job - a unit of background work
+type Job struct {
+	Id          int64           `json:"id" db:"id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      JobStatus       `json:"status" db:"status"`
+	Priority    int             `json:"priority" db:"priority"`
+	RunAt       time.Time       `json:"runAt" db:"run_at"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"maxAttempts" db:"max_attempts"`
+	UniqueKey   *string         `json:"uniqueKey,omitempty" db:"unique_key"`
+	LastError   *string         `json:"lastError,omitempty" db:"last_error"`
+	LockedBy    *string         `json:"lockedBy,omitempty" db:"locked_by"`
+	LockedUntil *time.Time      `json:"lockedUntil,omitempty" db:"locked_until"`
+	Created     time.Time       `json:"created" db:"created"`
+	Updated     time.Time       `json:"updated" db:"updated"`
+}
+
+// Decode - decodes the JSON payload of the job
+func (job *Job) Decode(out any) error {
+	if err := json.Unmarshal(job.Payload, out); err != nil {
+		return errx.Errf(err, "failed to decode payload of job %d of '%s'",
+			job.Id, job.Kind)
+	}
+	return nil
+}
+
+// NewJob - job to be enqueued
+type NewJob struct {
+	Kind    string
+	Payload any
+	// RunAt - time after which the job can run, now if zero
+	RunAt time.Time
+	// Priority - jobs with higher priority run first
+	Priority int
+	// UniqueKey - if given, the job is not enqueued if a pending or running
+	// job of same kind has the same key
+	UniqueKey string
+	// MaxAttempts - DefaultMaxAttempts is used if 0
+	MaxAttempts int
+}
+
+// CreateJobsTable - creates the jobs table and its indices if they do not
+// exist
+func CreateJobsTable(gtx context.Context) error {
+	if _, err := ConnFor(gtx, nil).ExecContext(gtx, jobsSchema); err != nil {
+		return errx.Errf(err, "failed to create jobs table")
+	}
+	return nil
+}
+
+// Enqueue - adds a job of the kind with JSON encoded payload, returns the
+// id of the job. If the context carries a transaction, the job becomes
+// visible to workers only when it is committed
+func Enqueue(
+	gtx context.Context,
+	kind string,
+	payload any,
+	runAt time.Time,
+	priority int) (int64, error) {
+	return EnqueueJob(gtx, &NewJob{
+		Kind:     kind,
+		Payload:  payload,
+		RunAt:    runAt,
+		Priority: priority,
+	})
+}
+
+// EnqueueJob - adds the job, returns ErrDuplicateJob if it has a unique key
+// and a job with same key is pending or running
+func EnqueueJob(gtx context.Context, job *NewJob) (int64, error) {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return 0, errx.Errf(err, "failed to encode payload of '%s' job",
+			job.Kind)
+	}
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	var uniqueKey *string
+	if job.UniqueKey != "" {
+		uniqueKey = &job.UniqueKey
+	}
+
+	query := `INSERT INTO libx_job (
+		kind, payload, priority, run_at, max_attempts, unique_key
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (kind, unique_key)
+		WHERE unique_key IS NOT NULL AND status IN ('pending', 'running')
+		DO NOTHING
+	RETURNING id`
+
+	ids := make([]int64, 0, 1)
+	err = ConnFor(gtx, nil).SelectContext(gtx, &ids, query,
+		job.Kind, string(payload), job.Priority, runAt, maxAttempts,
+		uniqueKey)
+	if err != nil {
+		return 0, errx.Errf(err, "failed to enqueue '%s' job", job.Kind)
+	}
+	if len(ids) == 0 {
+		return 0, errx.Errf(ErrDuplicateJob,
+			"'%s' job with unique key '%s' is already queued",
+			job.Kind, job.UniqueKey)
+	}
+
+	notifyWorkers(gtx, job.Kind)
+	return ids[0], nil
+}
+
+// notifyWorkers - wakes up the job workers. Workers poll anyway, so failing
+// to notify is only logged. The notification is sent under a savepoint if
+// there is a transaction in the context, so that a failure does not abort it
+func notifyWorkers(gtx context.Context, kind string) {
+	notify := func(gtx context.Context) error {
+		return Notify(gtx, JobsChannel, kind)
+	}
+	var err error
+	if TxFrom(gtx) != nil {
+		err = WithTx(gtx, nil, notify)
+	} else {
+		err = notify(gtx)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to notify job workers")
+	}
+}
+
+// jobSpecs - fields of jobs that can be used in filters and for sorting
+var jobSpecs = []*data.FilterSpec{
+	{Field: "id", Type: data.FtProp},
+	{Field: "kind", Type: data.FtProp},
+	{Field: "status", Type: data.FtProp},
+	{Field: "priority", Type: data.FtNumRange},
+	{Field: "attempts", Type: data.FtNumRange},
+	{Field: "uniqueKey", Column: "unique_key", Type: data.FtProp},
+	{Field: "runAt", Column: "run_at", Type: data.FtDateRange},
+	{Field: "created", Type: data.FtDateRange},
+	{Field: "updated", Type: data.FtDateRange},
+}
+
+// GetJobs - jobs matching the params, fields in filters and sorts are the
+// JSON names of Job fields. Most recently updated jobs are given first if
+// there is no sorting
+func GetJobs(
+	gtx context.Context, params *data.CommonParams) ([]*Job, error) {
+	// Params are copied so that the default sort is not set on caller's params
+	cp := data.CommonParams{}
+	if params != nil {
+		cp = *params
+	}
+	if len(cp.SortFields()) == 0 {
+		cp.Sorts = []data.SortField{{Field: "updated", Desc: true}}
+	}
+	sel, err := NewSelectorGenerator(jobSpecs...).SelectorX(&cp)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0)
+	query := "SELECT " + jobCols + " FROM libx_job" + sel.Clause()
+	err = ConnFor(gtx, nil).SelectContext(gtx, &jobs, query, sel.Args...)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to get jobs")
+	}
+	return jobs, nil
+}
+
+// RetryJobs - makes dead or pending jobs with the given ids run again now,
+// with attempts reset. If no ids are given all the dead jobs are retried.
+// Returns number of jobs that will be retried
+func RetryJobs(gtx context.Context, ids ...int64) (int64, error) {
+	query := `UPDATE libx_job
+		SET status = 'pending', attempts = 0, run_at = now(), updated = now()
+		WHERE status = 'dead'`
+	args := []any{}
+	if len(ids) != 0 {
+		query = `UPDATE libx_job
+		SET status = 'pending', attempts = 0, run_at = now(), updated = now()
+		WHERE status IN ('dead', 'pending') AND id = ANY($1)`
+		args = append(args, pq.Array(ids))
+	}
+
+	res, err := ConnFor(gtx, nil).ExecContext(gtx, query, args...)
+	if err != nil {
+		return 0, errx.Errf(err, "failed to retry jobs")
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errx.Errf(err, "failed to get number of jobs retried")
+	}
+	if count != 0 {
+		notifyWorkers(gtx, "")
+	}
+	return count, nil
+}
+
+// PurgeJobs - deletes jobs with any of the given statuses that were last
+// updated before the given duration. Running jobs can not be purged.
+// Returns number of jobs deleted
+func PurgeJobs(
+	gtx context.Context,
+	olderThan time.Duration,
+	statuses ...JobStatus) (int64, error) {
+	strs := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		if status == JobRunning {
+			return 0, errx.Fmt("running jobs can not be purged")
+		}
+		strs = append(strs, string(status))
+	}
+
+	query := `DELETE FROM libx_job
+		WHERE status = ANY($1)
+			AND updated < now() - make_interval(secs => $2)`
+	res, err := ConnFor(gtx, nil).ExecContext(
+		gtx, query, pq.Array(strs), olderThan.Seconds())
+	if err != nil {
+		return 0, errx.Errf(err, "failed to purge jobs")
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errx.Errf(err, "failed to get number of jobs purged")
+	}
+	return count, nil
+}
+
+// JobHandler - does the work of a job, the job is retried with backoff if it
+// returns an error. The context is cancelled when the job's lease expires or
+// the worker is stopped
+type JobHandler func(gtx context.Context, job *Job) error
+
+// JobWorker - runs jobs of the kinds it has handlers for. Jobs are leased
+// using FOR UPDATE SKIP LOCKED, so any number of workers can run against the
+// same table. A job whose lease expires, say because the worker died, is
+// picked up again
+type JobWorker struct {
+	// Concurrency - number of jobs run at the same time, 1 if not given
+	Concurrency int
+	// PollInterval - interval at which due jobs are looked for, 5 seconds if
+	// not given. Workers are also woken up when jobs are enqueued if Connect
+	// or ConnectWithOpts was used to connect to the database
+	PollInterval time.Duration
+	// Lease - time a job can run before it is considered abandoned, 5
+	// minutes if not given
+	Lease time.Duration
+	// Backoff - delay before the first retry of a failed job, it is doubled
+	// for each subsequent retry up to MaxBackoff. 10 seconds if not given
+	Backoff time.Duration
+	// MaxBackoff - 1 hour if not given
+	MaxBackoff time.Duration
+
+	id       string
+	handlers map[string]JobHandler
+}
+
+// NewJobWorker - creates a worker with default options
+func NewJobWorker() *JobWorker {
+	host, _ := os.Hostname()
+	return &JobWorker{
+		id: fmt.Sprintf("%s/%d/%x", host, os.Getpid(),
+			rand.Int63n(1<<32)),
+		handlers: map[string]JobHandler{},
+	}
+}
+
+// Handle - sets the handler for jobs of the kind
+func (jw *JobWorker) Handle(kind string, handler JobHandler) *JobWorker {
+	jw.handlers[kind] = handler
+	return jw
+}
+
+func (jw *JobWorker) defaults() {
+	jw.Concurrency = max(jw.Concurrency, 1)
+	if jw.PollInterval <= 0 {
+		jw.PollInterval = 5 * time.Second
+	}
+	if jw.Lease <= 0 {
+		jw.Lease = 5 * time.Minute
+	}
+	if jw.Backoff <= 0 {
+		jw.Backoff = 10 * time.Second
+	}
+	if jw.MaxBackoff <= 0 {
+		jw.MaxBackoff = time.Hour
+	}
+}
+
+// Run - runs jobs until the context is done, such as the one from rt.Gtx.
+// No jobs are leased after that, running jobs are cancelled and released so
+// that they run again without counting the attempt. Returns after the
+// running jobs return
+func (jw *JobWorker) Run(gtx context.Context) error {
+	if len(jw.handlers) == 0 {
+		return errx.Fmt("no job handlers given to the worker")
+	}
+	jw.defaults()
+	kinds := make([]string, 0, len(jw.handlers))
+	for kind := range jw.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	wake := make(chan struct{}, 1)
+	if ntfs, err := Listen(gtx, JobsChannel); err == nil {
+		go func() {
+			for range ntfs {
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	} else {
+		log.Debug().Err(err).Msg("job worker falls back to polling")
+	}
+
+	ticker := time.NewTicker(jw.PollInterval)
+	defer ticker.Stop()
+	finished := make(chan struct{}, jw.Concurrency)
+	running := 0
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	for {
+		if free := jw.Concurrency - running; free > 0 {
+			jobs, err := jw.lease(gtx, kinds, free)
+			if err != nil && gtx.Err() == nil {
+				log.Error().Err(err).Msg("failed to lease jobs")
+			}
+			for _, job := range jobs {
+				running++
+				wg.Add(1)
+				go func(job *Job) {
+					defer wg.Done()
+					jw.run(gtx, job)
+					finished <- struct{}{}
+				}(job)
+			}
+		}
+
+		select {
+		case <-gtx.Done():
+			return nil
+		case <-finished:
+			running--
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// lease - marks up to limit due jobs as running with this worker holding
+// them until the lease expires. Jobs with expired leases are also taken,
+// unless they have no attempts left in which case they are marked dead
+func (jw *JobWorker) lease(
+	gtx context.Context, kinds []string, limit int) ([]*Job, error) {
+	conn := ConnFor(gtx, nil)
+	_, err := conn.ExecContext(gtx, `UPDATE libx_job
+		SET status = 'dead', last_error = 'lease expired',
+			locked_by = NULL, locked_until = NULL, updated = now()
+		WHERE status = 'running' AND locked_until < now()
+			AND attempts >= max_attempts AND kind = ANY($1)`,
+		pq.Array(kinds))
+	if err != nil {
+		return nil, errx.Errf(err, "failed to mark abandoned jobs dead")
+	}
+
+	query := `UPDATE libx_job
+		SET status = 'running', attempts = attempts + 1,
+			locked_by = $1, locked_until = now() + make_interval(secs => $2),
+			updated = now()
+		WHERE id IN (
+			SELECT id FROM libx_job
+			WHERE kind = ANY($3) AND (
+				(status = 'pending' AND run_at <= now()) OR
+				(status = 'running' AND locked_until < now()))
+			ORDER BY priority DESC, run_at, id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING ` + jobCols
+
+	jobs := make([]*Job, 0, limit)
+	err = conn.SelectContext(gtx, &jobs, query,
+		jw.id, jw.Lease.Seconds(), pq.Array(kinds), limit)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to lease jobs")
+	}
+	return jobs, nil
+}
+
+func (jw *JobWorker) run(gtx context.Context, job *Job) {
+	jtx, cancel := context.WithTimeout(gtx, jw.Lease)
+	err := jw.call(jtx, job)
+	cancel()
+
+	// Bookkeeping should happen even if the worker is being stopped
+	btx, bcancel := context.WithTimeout(context.WithoutCancel(gtx),
+		10*time.Second)
+	defer bcancel()
+
+	var query string
+	args := []any{job.Id, jw.id}
+	switch {
+	case err == nil:
+		query = `UPDATE libx_job
+			SET status = 'done', last_error = NULL,
+				locked_by = NULL, locked_until = NULL, updated = now()
+			WHERE id = $1 AND locked_by = $2`
+	case gtx.Err() != nil:
+		query = `UPDATE libx_job
+			SET status = 'pending', attempts = attempts - 1, run_at = now(),
+				locked_by = NULL, locked_until = NULL, updated = now()
+			WHERE id = $1 AND locked_by = $2`
+	default:
+		log.Error().Err(err).
+			Int64("job", job.Id).
+			Str("kind", job.Kind).
+			Int("attempt", job.Attempts).
+			Msg("job failed")
+		query = `UPDATE libx_job
+			SET status = CASE WHEN attempts >= max_attempts
+					THEN 'dead' ELSE 'pending' END,
+				run_at = now() + make_interval(secs => $3),
+				last_error = $4,
+				locked_by = NULL, locked_until = NULL, updated = now()
+			WHERE id = $1 AND locked_by = $2`
+		args = append(args, jw.backoff(job.Attempts).Seconds(), err.Error())
+	}
+
+	_, err = ConnFor(btx, nil).ExecContext(btx, query, args...)
+	if err != nil {
+		log.Error().Err(err).Int64("job", job.Id).
+			Msg("failed to update job status")
+	}
+}
+
+// call - calls the handler, panics are returned as errors
+func (jw *JobWorker) call(gtx context.Context, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errx.Fmt("job handler panicked: %v", p)
+		}
+	}()
+	return jw.handlers[job.Kind](gtx, job)
+}
+
+// backoff - delay before retrying a job that failed in the given attempt,
+// with up to 20% jitter so that failed jobs are spread out
+func (jw *JobWorker) backoff(attempt int) time.Duration {
+	delay := jw.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		delay = min(jw.Backoff<<shift, jw.MaxBackoff)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
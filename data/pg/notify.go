@@ -0,0 +1,222 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrNotConnected = errors.New("pg.notConnected")
+	ErrNotify       = errors.New("pg.notify")
+)
+
+// maxPayload - postgres limit on size of notification payloads
+const maxPayload = 8000
+
+const (
+	minReconnect = 2 * time.Second
+	maxReconnect = time.Minute
+	pingInterval = 90 * time.Second
+)
+
+// Notification - a notification received on a channel. After the connection
+// is re-established, a notification with Reconnected set is given since
+// notifications sent in the meantime are lost
+type Notification struct {
+	Channel     string
+	Payload     string
+	PID         int
+	Reconnected bool
+}
+
+// Decode - decodes the JSON payload, see Notify
+func (n *Notification) Decode(out any) error {
+	if err := json.Unmarshal([]byte(n.Payload), out); err != nil {
+		return errx.Errf(err, "failed to decode payload of notification "+
+			"on '%s'", n.Channel)
+	}
+	return nil
+}
+
+// RowChange - payload of notifications sent by the trigger created by
+// NotifyTriggerSQL
+type RowChange struct {
+	Table string `json:"table"`
+	// Op - INSERT, UPDATE or DELETE
+	Op string `json:"op"`
+	// Row - new row, or the deleted row for DELETE
+	Row data.M `json:"row"`
+}
+
+// Listen - listens on the channels using a dedicated connection opened with
+// the options given to Connect or ConnectWithOpts, blocks until connected or
+// the context is done. Lost connection is re-established and the channels
+// are listened again. Notifications are given on the returned channel, which
+// is closed once the context is done. Receiving from the channel should not
+// be delayed, notifications are not read from the database in the meantime
+func Listen(
+	gtx context.Context, channels ...string) (<-chan *Notification, error) {
+	dsn := connStr.Load()
+	if dsn == nil {
+		return nil, errx.Errf(ErrNotConnected,
+			"connect to database before listening for notifications")
+	}
+	if len(channels) == 0 {
+		return nil, errx.Errf(ErrNotify, "no channels given to listen on")
+	}
+
+	lsn := pq.NewListener(*dsn, minReconnect, maxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventDisconnected:
+				log.Warn().Err(err).Msg("notification listener disconnected")
+			case pq.ListenerEventConnectionAttemptFailed:
+				log.Error().Err(err).
+					Msg("notification listener failed to reconnect")
+			case pq.ListenerEventReconnected:
+				log.Info().Msg("notification listener reconnected")
+			}
+		})
+
+	// Listen blocks until connected, closing the listener unblocks it
+	started := make(chan struct{})
+	go func() {
+		select {
+		case <-gtx.Done():
+			lsn.Close()
+		case <-started:
+		}
+	}()
+	for _, channel := range channels {
+		if err := lsn.Listen(channel); err != nil {
+			close(started)
+			lsn.Close()
+			return nil, errx.Errf(err, "failed to listen on '%s'", channel)
+		}
+	}
+	close(started)
+
+	out := make(chan *Notification, 64)
+	go relay(gtx, lsn, out)
+	return out, nil
+}
+
+func relay(gtx context.Context, lsn *pq.Listener, out chan<- *Notification) {
+	defer close(out)
+	defer lsn.Close()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		var ntf *Notification
+		select {
+		case <-gtx.Done():
+			return
+		case <-ticker.C:
+			// Detects broken connections when there are no notifications
+			go lsn.Ping()
+			continue
+		case pn, ok := <-lsn.Notify:
+			if !ok {
+				return
+			}
+			ntf = &Notification{Reconnected: true}
+			if pn != nil {
+				ntf = &Notification{
+					Channel: pn.Channel,
+					Payload: pn.Extra,
+					PID:     pn.BePid,
+				}
+			}
+		}
+
+		select {
+		case out <- ntf:
+		case <-gtx.Done():
+			return
+		}
+	}
+}
+
+// Notify - sends the JSON encoded payload on the channel. If the context
+// carries a transaction, the notification is sent when it is committed
+func Notify(gtx context.Context, channel string, payload any) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return errx.Errf(err, "failed to encode payload for '%s'", channel)
+	}
+	if len(js) >= maxPayload {
+		return errx.Errf(ErrNotify,
+			"payload for '%s' is %d bytes, should be less than %d bytes",
+			channel, len(js), maxPayload)
+	}
+
+	_, err = ConnFor(gtx, nil).ExecContext(
+		gtx, "SELECT pg_notify($1, $2)", channel, string(js))
+	if err != nil {
+		return errx.Errf(err, "failed to notify on '%s'", channel)
+	}
+	return nil
+}
+
+// NotifyTriggerSQL - SQL creating a trigger that sends a notification with
+// RowChange payload on the channel when rows of the table are inserted,
+// updated or deleted. If columns are given only those are sent in the row,
+// otherwise the whole row is sent; payloads larger than 8000 bytes fail the
+// change
+func NotifyTriggerSQL(table, channel string, cols ...string) string {
+	name := pq.QuoteIdentifier(
+		"notify_" + strings.ReplaceAll(table, ".", "_"))
+
+	row := "row_to_json(rec)"
+	if len(cols) != 0 {
+		fields := make([]string, 0, len(cols))
+		for _, col := range cols {
+			fields = append(fields,
+				pq.QuoteLiteral(col)+", rec."+pq.QuoteIdentifier(col))
+		}
+		row = "json_build_object(" + strings.Join(fields, ", ") + ")"
+	}
+
+	return `CREATE OR REPLACE FUNCTION ` + name + `() RETURNS trigger AS $$
+DECLARE
+	rec RECORD;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	PERFORM pg_notify(` + pq.QuoteLiteral(channel) + `, json_build_object(
+		'table', TG_TABLE_NAME,
+		'op', TG_OP,
+		'row', ` + row + `)::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ` + name + ` ON ` + QuoteIdent(table) + `;
+CREATE TRIGGER ` + name + `
+	AFTER INSERT OR UPDATE OR DELETE ON ` + QuoteIdent(table) + `
+	FOR EACH ROW EXECUTE FUNCTION ` + name + `();
+`
+}
+
+// CreateNotifyTrigger - creates the trigger given by NotifyTriggerSQL
+func CreateNotifyTrigger(
+	gtx context.Context, table, channel string, cols ...string) error {
+	query := NotifyTriggerSQL(table, channel, cols...)
+	if _, err := ConnFor(gtx, nil).ExecContext(gtx, query); err != nil {
+		return errx.Errf(err, "failed to create notify trigger for '%s'",
+			table)
+	}
+	return nil
+}
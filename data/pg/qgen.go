@@ -2,17 +2,63 @@ package pg
 
 import (
 	"context"
+	"reflect"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx/reflectx"
 	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
 )
 
+// NewGetterDeleter - creates a getter-deleter that does not allow filtering
+// or sorting on any field, see NewGetterDeleterWithSpecs
 func NewGetterDeleter() data.GetterDeleter {
 	return &getterDeleter{}
 }
 
+// NewUncheckedGetterDeleter - creates a getter-deleter that allows filtering
+// and sorting on any column. Should only be used with params that do not come
+// from clients, otherwise any column of the table can be probed
+func NewUncheckedGetterDeleter() data.GetterDeleter {
+	return &getterDeleter{unchecked: true}
+}
+
+// NewGetterDeleterWithSpecs - creates a getter-deleter that allows filtering
+// and sorting only on fields from the filter specs of the data type
+func NewGetterDeleterWithSpecs(
+	specs map[string][]*data.FilterSpec) data.GetterDeleter {
+	return &getterDeleter{specs: specs}
+}
+
 type getterDeleter struct {
+	specs     map[string][]*data.FilterSpec
+	cluster   *Cluster
+	unchecked bool
+}
+
+// reader - querier for reads, a replica if the getter-deleter is backed by a
+// cluster, see Cluster.Reader
+func (pgd *getterDeleter) reader(gtx context.Context) Querier {
+	if pgd.cluster != nil {
+		return pgd.cluster.Reader(gtx)
+	}
+	return ConnFor(gtx, nil)
+}
+
+// writer - querier for writes, see Cluster.Writer
+func (pgd *getterDeleter) writer(gtx context.Context) Querier {
+	if pgd.cluster != nil {
+		return pgd.cluster.Writer(gtx)
+	}
+	return ConnFor(gtx, nil)
+}
+
+func (pgd *getterDeleter) generator(dtype string) *SelectorGenerator {
+	if pgd.unchecked {
+		return NewUncheckedSelectorGenerator()
+	}
+	return NewSelectorGenerator(pgd.specs[dtype]...)
 }
 
 func (pgd *getterDeleter) Exists(
@@ -35,7 +81,8 @@ func (pgd *getterDeleter) Exists(
 	query := "SELECT EXISTS(" + sql + ")"
 
 	exists := false
-	if err := defDB.GetContext(gtx, &exists, query, args...); err != nil {
+	err = pgd.reader(gtx).GetContext(gtx, &exists, query, args...)
+	if err != nil {
 		return false, errx.Errf(err,
 			"failed to check object existance ('%s' => '%s' == '%s')",
 			dtype, keyField, id)
@@ -58,7 +105,7 @@ func (pgd *getterDeleter) Delete(
 	if err != nil {
 		return errx.Errf(err, "failed to build sql query")
 	}
-	if _, err = defDB.ExecContext(gtx, query, args...); err != nil {
+	if _, err = pgd.writer(gtx).ExecContext(gtx, query, args...); err != nil {
 		return errx.Errf(err, "failed to delete from %s", dataType)
 	}
 
@@ -83,7 +130,7 @@ func (pgd *getterDeleter) GetOne(
 	if err != nil {
 		return errx.Errf(err, "failed to build sql query")
 	}
-	if err = defDB.GetContext(gtx, data, query, args...); err != nil {
+	if err = pgd.reader(gtx).GetContext(gtx, data, query, args...); err != nil {
 		return errx.Errf(err, "failed to get item from '%s'", dataType)
 	}
 
@@ -95,20 +142,16 @@ func (pgd *getterDeleter) Count(
 	dtype string,
 	filter *data.Filter) (int64, error) {
 
-	sel := NewSelectorGenerator().Selector(filter)
-	sq := squirrel.StatementBuilder.
-		PlaceholderFormat(squirrel.Dollar).
-		Select("COUNT(*)").
-		From(dtype).
-		Where(sel.QueryFragment, sel.Args...)
-
-	query, args, err := sq.ToSql()
+	sel, err := pgd.generator(dtype).Selector(filter)
 	if err != nil {
-		return 0, errx.Errf(err, "failed to build sql query")
+		return 0, err
 	}
+	// Not built with squirrel, it would take '?' in the conditions, such as
+	// those in jsonpath literals, as placeholders
+	query := "SELECT COUNT(*) FROM " + dtype + sel.Clause()
 
 	count := int64(0)
-	err = defDB.SelectContext(gtx, &count, query, args...)
+	err = pgd.reader(gtx).GetContext(gtx, &count, query, sel.Args...)
 	if err != nil {
 		return 0, errx.Errf(
 			err, "failed to get count for data type '%s'", dtype)
@@ -116,24 +159,27 @@ func (pgd *getterDeleter) Count(
 	return count, nil
 }
 
+// Get - rows matching the params. If the element type of out has a field
+// for data.HighlightsColumn, ts_headline snippets of full text searches are
+// selected into it
 func (pgd *getterDeleter) Get(
 	gtx context.Context,
 	dtype string,
 	params *data.CommonParams,
 	out any) error {
-	sel := NewSelectorGenerator().SelectorX(params)
-	sq := squirrel.StatementBuilder.
-		PlaceholderFormat(squirrel.Dollar).
-		Select("*").
-		From(dtype).
-		Where(sel.QueryFragment, sel.Args...)
-	query, args, err := sq.ToSql()
-
+	sel, err := pgd.generator(dtype).SelectorX(params)
 	if err != nil {
-		return errx.Errf(err, "failed to build sql query")
+		return err
 	}
+	cols := "*"
+	if hasColumn(out, data.HighlightsColumn) {
+		cols += ", " + data.Qop(sel.Highlights == "", "NULL::jsonb",
+			sel.Highlights) + " AS " + data.HighlightsColumn
+	}
+	query := "SELECT " + cols + " FROM " + dtype + sel.Clause()
 
-	if err = defDB.SelectContext(gtx, out, query, args...); err != nil {
+	err = pgd.reader(gtx).SelectContext(gtx, out, query, sel.Args...)
+	if err != nil {
 		return errx.Errf(err, "failed to get data for type '%s'", dtype)
 	}
 	return nil
@@ -144,5 +190,23 @@ func (pgd *getterDeleter) FilterValues(
 	dtype string,
 	specs []*data.FilterSpec,
 	filter *data.Filter) (*data.FilterValues, error) {
-	return GetFilterValues(gtx, dtype, specs, filter)
+	return filterValues(gtx, pgd.reader(gtx), dtype, specs, filter)
+}
+
+// mapper - maps struct fields to columns the same way as sqlx.DB does by
+// default, independent of the connection in use
+var mapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// hasColumn - checks if the element type of the slice pointed to by out has a
+// field mapped to the column
+func hasColumn(out any, column string) bool {
+	typ := reflect.TypeOf(out)
+	for typ != nil && (typ.Kind() == reflect.Ptr ||
+		typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return false
+	}
+	return mapper.TypeMap(typ).GetByPath(column) != nil
 }
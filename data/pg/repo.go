@@ -0,0 +1,416 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// maxParams - maximum number of bind parameters postgres allows in a query
+const maxParams = 65535
+
+type repository[T any] struct {
+	db      *sqlx.DB
+	cluster *Cluster
+	info    *data.ModelInfo
+	table   string
+	cols    string
+	specs   []*data.FilterSpec
+}
+
+// NewRepository - creates a repository for T backed by given database, the
+// default connection is used if db is nil. Table, key and columns are taken
+// from 'db' tags of T, see data.NewModelInfo. Panics if T is not a valid model
+func NewRepository[T any](db *sqlx.DB) data.Repository[T] {
+	return newRepository[T](db)
+}
+
+func newRepository[T any](db *sqlx.DB) *repository[T] {
+	info, err := data.NewModelInfo[T]("db")
+	if err != nil {
+		panic(err)
+	}
+	// Only the columns of the model can be used in filters and for sorting
+	specs := make([]*data.FilterSpec, 0, len(info.Columns))
+	for _, col := range info.Columns {
+		specs = append(specs, &data.FilterSpec{Field: col.Name})
+	}
+	return &repository[T]{
+		db:    db,
+		info:  info,
+		table: QuoteIdent(info.Name),
+		cols:  strings.Join(quoteAll(info.ColumnNames()), ", "),
+		specs: specs,
+	}
+}
+
+func quoteAll(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		out = append(out, pq.QuoteIdentifier(name))
+	}
+	return out
+}
+
+// conn - querier for the repository's database, it is the transaction
+// carried by the context if there is one, see WithTx
+func (repo *repository[T]) conn(gtx context.Context) Querier {
+	return ConnFor(gtx, repo.db)
+}
+
+// reader - querier for reads, a replica if the repository is backed by a
+// cluster, see Cluster.Reader
+func (repo *repository[T]) reader(gtx context.Context) Querier {
+	if repo.cluster != nil {
+		return repo.cluster.Reader(gtx)
+	}
+	return repo.conn(gtx)
+}
+
+func (repo *repository[T]) builder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+}
+
+func (repo *repository[T]) keyCol() string {
+	return pq.QuoteIdentifier(repo.info.Key.Name)
+}
+
+func (repo *repository[T]) Info() *data.ModelInfo {
+	return repo.info
+}
+
+func (repo *repository[T]) Get(gtx context.Context, key any) (*T, error) {
+	query, args, err := repo.builder().
+		Select(repo.cols).
+		From(repo.table).
+		Where(squirrel.Eq{repo.keyCol(): key}).
+		ToSql()
+	if err != nil {
+		return nil, errx.Errf(err, "failed to build sql query")
+	}
+
+	var out T
+	err = repo.reader(gtx).GetContext(gtx, &out, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errx.With(errx.Errf(data.ErrNotFound,
+			"no item in '%s'", repo.info.Name), "key", key)
+	}
+	if err != nil {
+		return nil, errx.With(
+			errx.Errf(err, "failed to get item from '%s'", repo.info.Name),
+			"key", key)
+	}
+	return &out, nil
+}
+
+func (repo *repository[T]) List(
+	gtx context.Context, params *data.CommonParams) ([]T, error) {
+	return repo.list(gtx, params, nil)
+}
+
+func (repo *repository[T]) ListPage(
+	gtx context.Context, params *data.CommonParams) (*data.Page[T], error) {
+	if params == nil {
+		params = &data.CommonParams{}
+	}
+	ks, err := data.NewKeyset(repo.info, params)
+	if err != nil {
+		return nil, err
+	}
+	items, err := repo.list(gtx, params, ks)
+	if err != nil {
+		return nil, err
+	}
+	return data.NewPage(repo.info, ks, params, items), nil
+}
+
+func (repo *repository[T]) list(
+	gtx context.Context,
+	params *data.CommonParams,
+	ks *data.Keyset) ([]T, error) {
+	if params == nil {
+		params = &data.CommonParams{}
+	}
+
+	sel, err := NewSelectorGenerator(repo.specs...).
+		WithKeyset(ks).
+		SelectorX(params)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + repo.cols + " FROM " + repo.table + sel.Clause()
+
+	out := make([]T, 0)
+	err = repo.reader(gtx).SelectContext(gtx, &out, query, sel.Args...)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to list items from '%s'",
+			repo.info.Name)
+	}
+	return out, nil
+}
+
+func (repo *repository[T]) Count(
+	gtx context.Context, filter *data.Filter) (int64, error) {
+	sel, err := NewSelectorGenerator(repo.specs...).Selector(filter)
+	if err != nil {
+		return 0, err
+	}
+	// Not built with squirrel, it would take '?' in the conditions, such as
+	// those in jsonpath literals, as placeholders
+	query := "SELECT COUNT(*) FROM " + repo.table + sel.Clause()
+
+	count := int64(0)
+	err = repo.reader(gtx).GetContext(gtx, &count, query, sel.Args...)
+	if err != nil {
+		return 0, errx.Errf(err, "failed to count items in '%s'",
+			repo.info.Name)
+	}
+	return count, nil
+}
+
+func (repo *repository[T]) Exists(gtx context.Context, key any) (bool, error) {
+	sql, args, err := repo.builder().
+		Select("1").
+		From(repo.table).
+		Where(squirrel.Eq{repo.keyCol(): key}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return false, errx.Errf(err, "failed to build sql query")
+	}
+
+	exists := false
+	err = repo.reader(gtx).GetContext(
+		gtx, &exists, "SELECT EXISTS("+sql+")", args...)
+	if err != nil {
+		return false, errx.Errf(err,
+			"failed to check existence of item in '%s'", repo.info.Name)
+	}
+	return exists, nil
+}
+
+func (repo *repository[T]) FilterValues(
+	gtx context.Context,
+	specs []*data.FilterSpec,
+	filter *data.Filter) (*data.FilterValues, error) {
+	return filterValues(gtx, repo.reader(gtx), repo.table, specs, filter)
+}
+
+func (repo *repository[T]) Insert(gtx context.Context, item *T) error {
+	cols, vals := repo.info.Values(item, !repo.info.IsNewItem(item))
+	sq := repo.builder().
+		Insert(repo.table).
+		Columns(quoteAll(cols)...).
+		Values(vals...)
+
+	if !repo.info.Key.Auto {
+		query, args, err := sq.ToSql()
+		if err != nil {
+			return errx.Errf(err, "failed to build sql query")
+		}
+		if _, err := repo.conn(gtx).ExecContext(gtx, query, args...); err != nil {
+			return errx.Errf(err, "failed to insert item into '%s'",
+				repo.info.Name)
+		}
+		return nil
+	}
+
+	query, args, err := sq.Suffix("RETURNING " + repo.keyCol()).ToSql()
+	if err != nil {
+		return errx.Errf(err, "failed to build sql query")
+	}
+	err = repo.conn(gtx).QueryRowxContext(gtx, query, args...).
+		Scan(repo.info.KeyPtr(item))
+	if err != nil {
+		return errx.Errf(err, "failed to insert item into '%s'",
+			repo.info.Name)
+	}
+	return nil
+}
+
+// BulkInsert - inserts the items in a transaction using multi row inserts.
+// Generated keys are set in the items
+func (repo *repository[T]) BulkInsert(gtx context.Context, items []*T) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	cols, _ := repo.info.Values(items[0], false)
+	if len(cols) > maxParams {
+		return errx.Fmt("'%s' has %d columns, at most %d can be inserted",
+			repo.info.Name, len(cols), maxParams)
+	}
+	batchSize := maxParams / len(cols)
+
+	opts := &TxOpts{DB: repo.db}
+	return WithTx(gtx, opts, func(gtx context.Context) error {
+		for start := 0; start < len(items); start += batchSize {
+			end := min(start+batchSize, len(items))
+			err := repo.insertBatch(gtx, cols, items[start:end])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *repository[T]) insertBatch(
+	gtx context.Context, cols []string, items []*T) error {
+	tx := repo.conn(gtx)
+	sq := repo.builder().
+		Insert(repo.table).
+		Columns(quoteAll(cols)...)
+	for _, item := range items {
+		_, vals := repo.info.Values(item, false)
+		sq = sq.Values(vals...)
+	}
+
+	if !repo.info.Key.Auto {
+		query, args, err := sq.ToSql()
+		if err != nil {
+			return errx.Errf(err, "failed to build sql query")
+		}
+		if _, err := tx.ExecContext(gtx, query, args...); err != nil {
+			return errx.Errf(err, "failed to bulk insert into '%s'",
+				repo.info.Name)
+		}
+		return nil
+	}
+
+	// Rows from RETURNING are in the order of VALUES for a single INSERT
+	query, args, err := sq.Suffix("RETURNING " + repo.keyCol()).ToSql()
+	if err != nil {
+		return errx.Errf(err, "failed to build sql query")
+	}
+	rows, err := tx.QueryxContext(gtx, query, args...)
+	if err != nil {
+		return errx.Errf(err, "failed to bulk insert into '%s'",
+			repo.info.Name)
+	}
+	defer rows.Close()
+	for idx := 0; rows.Next() && idx < len(items); idx++ {
+		if err := rows.Scan(repo.info.KeyPtr(items[idx])); err != nil {
+			return errx.Errf(err, "failed to read generated key")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errx.Errf(err, "failed to bulk insert into '%s'",
+			repo.info.Name)
+	}
+	return nil
+}
+
+func (repo *repository[T]) Update(gtx context.Context, item *T) error {
+	cols, vals := repo.info.Values(item, true)
+	fields := make(map[string]any, len(cols))
+	for idx, col := range cols {
+		if col != repo.info.Key.Name {
+			fields[col] = vals[idx]
+		}
+	}
+	return repo.update(gtx, repo.info.KeyValue(item), fields)
+}
+
+func (repo *repository[T]) UpdateFields(
+	gtx context.Context, key any, fields map[string]any) error {
+	for name := range fields {
+		if err := repo.info.CheckFields(name); err != nil {
+			return err
+		}
+		if name == repo.info.Key.Name {
+			return errx.Errf(data.ErrUnknownField,
+				"key field '%s' cannot be updated", name)
+		}
+	}
+	return repo.update(gtx, key, fields)
+}
+
+func (repo *repository[T]) update(
+	gtx context.Context, key any, fields map[string]any) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	quoted := make(map[string]any, len(fields))
+	for name, val := range fields {
+		quoted[pq.QuoteIdentifier(name)] = val
+	}
+	query, args, err := repo.builder().
+		Update(repo.table).
+		SetMap(quoted).
+		Where(squirrel.Eq{repo.keyCol(): key}).
+		ToSql()
+	if err != nil {
+		return errx.Errf(err, "failed to build sql query")
+	}
+
+	res, err := repo.conn(gtx).ExecContext(gtx, query, args...)
+	if err != nil {
+		return errx.Errf(err, "failed to update item in '%s'", repo.info.Name)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return errx.With(errx.Errf(data.ErrNotFound,
+			"no item to update in '%s'", repo.info.Name), "key", key)
+	}
+	return nil
+}
+
+func (repo *repository[T]) Upsert(gtx context.Context, item *T) error {
+	if repo.info.IsNewItem(item) {
+		return repo.Insert(gtx, item)
+	}
+
+	cols, vals := repo.info.Values(item, true)
+	sets := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if col == repo.info.Key.Name {
+			continue
+		}
+		qc := pq.QuoteIdentifier(col)
+		sets = append(sets, qc+" = EXCLUDED."+qc)
+	}
+	conflict := " DO NOTHING"
+	if len(sets) != 0 {
+		conflict = " DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	query, args, err := repo.builder().
+		Insert(repo.table).
+		Columns(quoteAll(cols)...).
+		Values(vals...).
+		Suffix("ON CONFLICT (" + repo.keyCol() + ")" + conflict).
+		ToSql()
+	if err != nil {
+		return errx.Errf(err, "failed to build sql query")
+	}
+	if _, err := repo.conn(gtx).ExecContext(gtx, query, args...); err != nil {
+		return errx.Errf(err, "failed to upsert item into '%s'",
+			repo.info.Name)
+	}
+	return nil
+}
+
+func (repo *repository[T]) Delete(gtx context.Context, keys ...any) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	query, args, err := repo.builder().
+		Delete(repo.table).
+		Where(squirrel.Eq{repo.keyCol(): keys}).
+		ToSql()
+	if err != nil {
+		return errx.Errf(err, "failed to build sql query")
+	}
+	if _, err := repo.conn(gtx).ExecContext(gtx, query, args...); err != nil {
+		return errx.Errf(err, "failed to delete from '%s'", repo.info.Name)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+var ErrTableNotFound = errors.New("pg.tableNotFound")
+
+// ColumnInfo - a column of a table in the database
+type ColumnInfo struct {
+	Name string `json:"name" db:"name"`
+	// Type - type as given by format_type, such as 'character varying(60)'
+	Type     string  `json:"type" db:"type"`
+	Nullable bool    `json:"nullable" db:"nullable"`
+	Default  *string `json:"default,omitempty" db:"default_expr"`
+	// Identity - true for identity and serial columns
+	Identity bool `json:"identity" db:"identity"`
+	Position int  `json:"position" db:"position"`
+}
+
+// IndexInfo - an index of a table in the database, Columns is empty for
+// indexes on expressions
+type IndexInfo struct {
+	Name       string           `json:"name" db:"name"`
+	Columns    data.Vec[string] `json:"columns" db:"columns"`
+	Unique     bool             `json:"unique" db:"is_unique"`
+	Primary    bool             `json:"primary" db:"is_primary"`
+	Definition string           `json:"definition" db:"definition"`
+}
+
+// ConstraintInfo - a constraint on a table in the database
+type ConstraintInfo struct {
+	Name string `json:"name" db:"name"`
+	// Type - one of 'PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY', 'CHECK' and
+	// 'EXCLUDE'
+	Type       string           `json:"type" db:"type"`
+	Columns    data.Vec[string] `json:"columns" db:"columns"`
+	Definition string           `json:"definition" db:"definition"`
+}
+
+// TableInfo - structure of a table in the database
+type TableInfo struct {
+	Schema      string            `json:"schema"`
+	Name        string            `json:"name"`
+	Columns     []*ColumnInfo     `json:"columns"`
+	Indexes     []*IndexInfo      `json:"indexes"`
+	Constraints []*ConstraintInfo `json:"constraints"`
+}
+
+// Column - column with given name, nil if there is no such column
+func (ti *TableInfo) Column(name string) *ColumnInfo {
+	for _, col := range ti.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// PrimaryKey - columns of the primary key, nil if there is none
+func (ti *TableInfo) PrimaryKey() []string {
+	for _, con := range ti.Constraints {
+		if con.Type == "PRIMARY KEY" {
+			return con.Columns
+		}
+	}
+	return nil
+}
+
+// Index - index with the single column, nil if there is none. Unique indexes
+// are preferred
+func (ti *TableInfo) Index(col string) *IndexInfo {
+	var found *IndexInfo
+	for _, idx := range ti.Indexes {
+		if len(idx.Columns) != 1 || idx.Columns[0] != col || idx.Primary {
+			continue
+		}
+		if found == nil || idx.Unique {
+			found = idx
+		}
+	}
+	return found
+}
+
+const columnsQuery = `
+SELECT
+	a.attname AS name,
+	format_type(a.atttypid, a.atttypmod) AS type,
+	NOT a.attnotnull AS nullable,
+	pg_get_expr(d.adbin, d.adrelid) AS default_expr,
+	(a.attidentity <> '' OR
+		coalesce(pg_get_expr(d.adbin, d.adrelid), '') LIKE 'nextval(%')
+		AS identity,
+	a.attnum AS position
+FROM pg_attribute a
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`
+
+const indexesQuery = `
+SELECT
+	i.relname AS name,
+	ARRAY(
+		SELECT a.attname
+		FROM unnest(x.indkey) WITH ORDINALITY AS k(num, ord)
+		JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = k.num
+		ORDER BY k.ord
+	)::text[] AS columns,
+	x.indisunique AS is_unique,
+	x.indisprimary AS is_primary,
+	pg_get_indexdef(x.indexrelid) AS definition
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+WHERE x.indrelid = $1
+ORDER BY i.relname`
+
+const constraintsQuery = `
+SELECT
+	c.conname AS name,
+	CASE c.contype
+		WHEN 'p' THEN 'PRIMARY KEY'
+		WHEN 'u' THEN 'UNIQUE'
+		WHEN 'f' THEN 'FOREIGN KEY'
+		WHEN 'c' THEN 'CHECK'
+		WHEN 'x' THEN 'EXCLUDE'
+		ELSE c.contype::text
+	END AS type,
+	ARRAY(
+		SELECT a.attname
+		FROM unnest(c.conkey) WITH ORDINALITY AS k(num, ord)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.num
+		ORDER BY k.ord
+	)::text[] AS columns,
+	pg_get_constraintdef(c.oid) AS definition
+FROM pg_constraint c
+WHERE c.conrelid = $1
+ORDER BY c.conname`
+
+// Introspect - reads the structure of the table from pg_catalog. The table
+// can be qualified with schema, otherwise it is looked up in the search
+// path. Returns ErrTableNotFound if there is no such table
+func Introspect(gtx context.Context, table string) (*TableInfo, error) {
+	type relation struct {
+		Oid    int64  `db:"oid"`
+		Schema string `db:"schema"`
+		Name   string `db:"name"`
+	}
+	conn := ConnFor(gtx, nil)
+	rels := make([]*relation, 0, 1)
+	err := conn.SelectContext(gtx, &rels, `
+		SELECT c.oid::bigint AS oid, n.nspname AS schema, c.relname AS name
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.oid = to_regclass($1)`, QuoteIdent(table))
+	if err != nil {
+		return nil, errx.Errf(err, "failed to find table '%s'", table)
+	}
+	if len(rels) == 0 {
+		return nil, errx.Errf(ErrTableNotFound, "table '%s' not found", table)
+	}
+	rel := rels[0]
+
+	ti := &TableInfo{Schema: rel.Schema, Name: rel.Name}
+	err = conn.SelectContext(gtx, &ti.Columns, columnsQuery, rel.Oid)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to get columns of '%s'", table)
+	}
+	err = conn.SelectContext(gtx, &ti.Indexes, indexesQuery, rel.Oid)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to get indexes of '%s'", table)
+	}
+	err = conn.SelectContext(gtx, &ti.Constraints, constraintsQuery, rel.Oid)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to get constraints of '%s'",
+			table)
+	}
+	return ti, nil
+}
+
+// Drift - a difference between a model and its table in the database
+type Drift struct {
+	Column string `json:"column,omitempty"`
+	// Issue - one of 'missing column', 'extra column', 'type', 'nullable',
+	// 'primary key' and 'index'
+	Issue    string `json:"issue"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// CheckDrift - differences between the model, with column types and options
+// taken like in TableSQL, and the table in the database. Defaults are not
+// compared since the database gives them in normalized form. Types given in
+// 'pg' tags are compared after resolving aliases, such as INT for integer
+func CheckDrift(
+	gtx context.Context, mi *data.ModelInfo) ([]*Drift, error) {
+	cols, err := modelColumns(mi)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := Introspect(gtx, mi.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]*Drift, 0, 10)
+	add := func(col, issue, expected, actual string) {
+		drifts = append(drifts, &Drift{
+			Column:   col,
+			Issue:    issue,
+			Expected: expected,
+			Actual:   actual,
+		})
+	}
+
+	pk := make([]string, 0, 1)
+	for _, col := range cols {
+		if col.pk {
+			pk = append(pk, col.name)
+		}
+		live := ti.Column(col.name)
+		if live == nil {
+			add(col.name, "missing column", col.typ, "")
+			continue
+		}
+		if canonicalType(col.typ) != canonicalType(live.Type) {
+			add(col.name, "type", col.typ, live.Type)
+		}
+		if col.nullable != live.Nullable {
+			add(col.name, "nullable", nullability(col.nullable),
+				nullability(live.Nullable))
+		}
+
+		idx := ti.Index(col.name)
+		switch {
+		case col.unique && (idx == nil || !idx.Unique):
+			add(col.name, "index", "unique index", indexKind(idx))
+		case col.index && idx == nil:
+			add(col.name, "index", "index", indexKind(idx))
+		}
+	}
+
+	for _, live := range ti.Columns {
+		if !hasModelColumn(cols, live.Name) {
+			add(live.Name, "extra column", "", live.Type)
+		}
+	}
+	if livePk := ti.PrimaryKey(); strings.Join(pk, ",") !=
+		strings.Join(livePk, ",") {
+		add("", "primary key", strings.Join(pk, ", "),
+			strings.Join(livePk, ", "))
+	}
+	return drifts, nil
+}
+
+func nullability(nullable bool) string {
+	return data.Qop(nullable, "NULL", "NOT NULL")
+}
+
+func indexKind(idx *IndexInfo) string {
+	switch {
+	case idx == nil:
+		return "none"
+	case idx.Unique:
+		return "unique index"
+	}
+	return "index"
+}
+
+func hasModelColumn(cols []*colSpec, name string) bool {
+	for _, col := range cols {
+		if col.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// typeAliases - alternate names of types mapped to the names given by
+// format_type
+var typeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"bool":        "boolean",
+	"float":       "double precision",
+	"float8":      "double precision",
+	"float4":      "real",
+	"decimal":     "numeric",
+	"varchar":     "character varying",
+	"char":        "character",
+	"bpchar":      "character",
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+	"timetz":      "time with time zone",
+	"time":        "time without time zone",
+	"varbit":      "bit varying",
+}
+
+// canonicalType - type name as given by format_type, for comparing types
+func canonicalType(typ string) string {
+	typ = strings.ToLower(strings.Join(strings.Fields(typ), " "))
+	suffix := ""
+	for strings.HasSuffix(typ, "[]") {
+		typ = strings.TrimSpace(strings.TrimSuffix(typ, "[]"))
+		suffix += "[]"
+	}
+
+	// Modifiers such as length and precision, given in parentheses
+	base, mod := typ, ""
+	if start := strings.Index(typ, "("); start != -1 {
+		if end := strings.Index(typ[start:], ")"); end != -1 {
+			mod = strings.ReplaceAll(typ[start:start+end+1], " ", "")
+			base = strings.TrimSpace(typ[:start] + " " + typ[start+end+1:])
+			base = strings.Join(strings.Fields(base), " ")
+		}
+	}
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+	if base == "character" && mod == "" {
+		mod = "(1)"
+	}
+
+	// Precision of time types comes before the time zone part
+	head, tail, found := strings.Cut(base, " ")
+	if mod != "" && found && (head == "timestamp" || head == "time") {
+		return head + mod + " " + tail + suffix
+	}
+	return base + mod + suffix
+}
@@ -0,0 +1,166 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+	"github.com/varunamachi/libx/iox"
+)
+
+var ErrSchemaDrift = errors.New("pg.schemaDrift")
+
+// SchemaCommands - commands for inspecting tables and for generating and
+// checking the schema of the given models
+func SchemaCommands(models ...*data.ModelInfo) *cli.Command {
+	return Wrap(&cli.Command{
+		Name:        "schema",
+		Description: "Commands for inspecting and generating table schemas",
+		Subcommands: []*cli.Command{
+			inspectCmd(),
+			genStructCmd(),
+			ddlCmd(models),
+			checkCmd(models),
+		},
+	})
+}
+
+func inspectCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "inspect",
+		Description: "Prints columns, indexes and constraints of a table",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "table",
+				Usage:    "Table to inspect, can be qualified with schema",
+				Required: true,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			ti, err := Introspect(ctx.Context, ctx.String("table"))
+			if err != nil {
+				return err
+			}
+			iox.PrintJSON(ti)
+			return nil
+		},
+	}
+}
+
+func genStructCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "gen-struct",
+		Description: "Prints Go struct for a table",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "table",
+				Usage:    "Table to generate the struct for",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "package",
+				Value: "model",
+				Usage: "Package of the generated source",
+			},
+			&cli.StringFlag{
+				Name:  "type",
+				Usage: "Name of the struct, derived from table name if empty",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			ti, err := Introspect(ctx.Context, ctx.String("table"))
+			if err != nil {
+				return err
+			}
+			src, err := StructSource(
+				ti, ctx.String("package"), ctx.String("type"))
+			if err != nil {
+				return err
+			}
+			fmt.Print(src)
+			return nil
+		},
+	}
+}
+
+func ddlCmd(models []*data.ModelInfo) *cli.Command {
+	return &cli.Command{
+		Name:        "ddl",
+		Description: "Prints CREATE TABLE statements for the models",
+		Flags: []cli.Flag{
+			modelFlag(),
+		},
+		Action: func(ctx *cli.Context) error {
+			selected, err := selectModels(models, ctx.String("model"))
+			if err != nil {
+				return err
+			}
+			for _, mi := range selected {
+				ddl, err := TableSQL(mi)
+				if err != nil {
+					return err
+				}
+				fmt.Println(ddl)
+			}
+			return nil
+		},
+	}
+}
+
+func checkCmd(models []*data.ModelInfo) *cli.Command {
+	return &cli.Command{
+		Name: "check",
+		Description: "Reports differences between the models and their " +
+			"tables, fails if there are any",
+		Flags: []cli.Flag{
+			modelFlag(),
+		},
+		Action: func(ctx *cli.Context) error {
+			selected, err := selectModels(models, ctx.String("model"))
+			if err != nil {
+				return err
+			}
+
+			report := map[string][]*Drift{}
+			for _, mi := range selected {
+				drifts, err := CheckDrift(ctx.Context, mi)
+				if err != nil {
+					return err
+				}
+				if len(drifts) != 0 {
+					report[mi.Name] = drifts
+				}
+			}
+			if len(report) == 0 {
+				fmt.Println("no drift found")
+				return nil
+			}
+			iox.PrintJSON(report)
+			return errx.Errf(ErrSchemaDrift,
+				"%d of %d tables differ from their models",
+				len(report), len(selected))
+		},
+	}
+}
+
+func modelFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "model",
+		Usage: "Table name of the model to act on, all models if empty",
+	}
+}
+
+func selectModels(
+	models []*data.ModelInfo, name string) ([]*data.ModelInfo, error) {
+	if name == "" {
+		return models, nil
+	}
+	for _, mi := range models {
+		if mi.Name == name {
+			return []*data.ModelInfo{mi}, nil
+		}
+	}
+	return nil, errx.Fmt("no model for table '%s'", name)
+}
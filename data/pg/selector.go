@@ -1,14 +1,25 @@
 package pg
 
 import (
+	"encoding/json"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/lib/pq"
 	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
 )
 
+// Selector - generated conditions and arguments. QueryFragment holds the
+// conditions for WHERE clause, Suffix holds ORDER BY, LIMIT and OFFSET clauses.
+// Highlights is an expression giving a JSON object with ts_headline snippets
+// of full text search matches, it is empty if there are no such searches
 type Selector struct {
 	QueryFragment string
+	Suffix        string
+	Highlights    string
 	Args          []interface{}
 }
 
@@ -16,6 +27,15 @@ func (sel Selector) IsEmpty() bool {
 	return sel.QueryFragment == "" && len(sel.Args) == 0
 }
 
+// Clause - WHERE clause followed by the suffix, to be appended to a query
+// that selects from a table
+func (sel Selector) Clause() string {
+	if sel.QueryFragment == "" {
+		return sel.Suffix
+	}
+	return " WHERE " + sel.QueryFragment + sel.Suffix
+}
+
 func NewSel(qf string, args []interface{}) Selector {
 	return Selector{
 		QueryFragment: qf,
@@ -37,126 +57,357 @@ func (buf *buffer) writeInt(val int64) *buffer {
 	return buf
 }
 
-// func GenQueryX(
-// 	params *data.CommonParams,
-// 	fmtStr string,
-// 	fargs ...string) Selector {
-// 	selector := NewSelectorGenerator().SelectorX(params)
-// 	return NewSel(
-// 		fmt.Sprintf(fmtStr, fargs)+" WHERE "+selector.QueryFragment,
-// 		selector.Args,
-// 	)
-// }
-
-// func GenQuery(
-// 	filter *data.Filter,
-// 	fmtStr string,
-// 	fargs ...string) Selector {
-// 	selector := NewSelectorGenerator().Selector(filter)
-// 	return NewSel(
-// 		fmt.Sprintf(fmtStr, fargs)+" WHERE "+selector.QueryFragment,
-// 		selector.Args,
-// 	)
-// }
+// identRx - plain identifiers, optionally qualified with table name
+var identRx = regexp.MustCompile(
+	`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// jsonKeyRx - keys allowed in JSON paths, these are inlined in the queries
+var jsonKeyRx = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// QuoteIdent - quotes the identifier, each part of a qualified name is quoted
+// separately
+func QuoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for idx, part := range parts {
+		parts[idx] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
 
+// SelectorGenerator - generates SQL conditions from data.Filter. Field names
+// in the filter and sort field are checked against the filter specs given to
+// the generator and mapped to the quoted column for that spec. Without specs
+// no field is allowed, see NewUncheckedSelectorGenerator. Searches use full
+// text or trigram matching if the field's spec has Search options, see
+// data.TextSearch, in which case rows can be sorted by data.SortRank
 type SelectorGenerator struct {
-	// filter      *data.Filter
 	dollerIndex int64
 	_args       []interface{}
 	fragments   []string
+	specs       map[string]*data.FilterSpec
+	keyset      *data.Keyset
+	errs        errx.Multi
+	ranks       []string
+	headlines   []string
+	unchecked   bool
+}
+
+// NewSelectorGenerator - generator that allows only the fields from given
+// specs in filters and sorting, any other field is rejected with status 400
+func NewSelectorGenerator(specs ...*data.FilterSpec) *SelectorGenerator {
+	gen := &SelectorGenerator{
+		specs: make(map[string]*data.FilterSpec, len(specs)),
+	}
+	for _, spec := range specs {
+		gen.specs[spec.Field] = spec
+	}
+	return gen
 }
 
-func NewSelectorGenerator() *SelectorGenerator {
-	return &SelectorGenerator{}
+// NewUncheckedSelectorGenerator - generator that allows any plain identifier
+// as a field. Should only be used with filters that do not come from clients,
+// otherwise any column of the table can be probed
+func NewUncheckedSelectorGenerator() *SelectorGenerator {
+	return &SelectorGenerator{unchecked: true}
 }
 
 func (gen *SelectorGenerator) Reset() *SelectorGenerator {
 	gen.dollerIndex = 1
 	gen._args = make([]interface{}, 0, 100)
 	gen.fragments = make([]string, 0, 30)
+	gen.errs = errx.Multi{}
+	gen.ranks = nil
+	gen.headlines = nil
 	return gen
 }
 
-func (gen *SelectorGenerator) Selector(filter *data.Filter) Selector {
-	if filter == nil {
-		return Selector{}
+// Column - quoted column for the field if it is allowed, returns an error
+// with status 400 otherwise. For keys in a field with data.FtJson spec, such
+// as 'metadata.plan', it is the jsonb value at the path in the column
+func (gen *SelectorGenerator) Column(field string) (string, error) {
+	col, path, err := gen.target(field)
+	if err != nil {
+		return "", err
 	}
+	return jsonValue(col, path, false), nil
+}
 
-	gen.Reset().
-		matchers(filter.Props).
-		bools(filter.Bools).
-		dateRanges(filter.Dates).
-		ranges(filter.Ranges).
-		searches(filter.Searches)
+// target - quoted column for the field and the path within the column if the
+// field is a key in a JSON field. Keys in JSON paths can only have letters,
+// digits, '_' and '-'
+func (gen *SelectorGenerator) target(field string) (string, []string, error) {
+	if gen.unchecked {
+		if !identRx.MatchString(field) {
+			return "", nil, errx.Errf(data.ErrUnknownField,
+				"invalid filter/sort field '%s'", field)
+		}
+		return QuoteIdent(field), nil, nil
+	}
 
-	buf := buffer{}
-	for idx, frag := range gen.fragments {
-		buf.write(frag)
-		if idx != 0 && idx < len(gen.fragments)-1 {
-			buf.write(" AND ")
+	if spec, found := gen.specs[field]; found {
+		return QuoteIdent(spec.ColumnName()), nil, nil
+	}
+	base, rest, isPath := strings.Cut(field, ".")
+	spec := gen.specs[base]
+	if !isPath || spec == nil || spec.Type != data.FtJson {
+		return "", nil, errx.Errf(data.ErrUnknownField,
+			"unknown filter/sort field '%s'", field)
+	}
+	path := strings.Split(rest, ".")
+	for _, key := range path {
+		if !jsonKeyRx.MatchString(key) {
+			return "", nil, errx.Errf(data.ErrUnknownField,
+				"invalid key '%s' in JSON field '%s'", key, field)
 		}
 	}
-	return NewSel(buf.String(), gen._args)
+	return QuoteIdent(spec.ColumnName()), path, nil
+}
 
+// col - column for the field, errors are collected and returned by the
+// selector functions
+func (gen *SelectorGenerator) col(field string) (string, bool) {
+	col, err := gen.Column(field)
+	if err != nil {
+		gen.errs.Add(err)
+		return "", false
+	}
+	return col, true
 }
 
-func (gen *SelectorGenerator) SelectorX(cmnParam *data.CommonParams) Selector {
+// ref - column and JSON path for the field, errors are collected like col
+func (gen *SelectorGenerator) ref(field string) (string, []string, bool) {
+	col, path, err := gen.target(field)
+	if err != nil {
+		gen.errs.Add(err)
+		return "", nil, false
+	}
+	return col, path, true
+}
 
-	// TODO -fix ANDs
+func (gen *SelectorGenerator) Selector(filter *data.Filter) (Selector, error) {
+	if filter == nil {
+		return Selector{}, nil
+	}
 
-	gen.Reset().
-		matchers(cmnParam.Filter.Props).
-		matchers(cmnParam.Filter.Lists).
-		bools(cmnParam.Filter.Bools).
-		dateRanges(cmnParam.Filter.Dates).
-		ranges(cmnParam.Filter.Ranges).
-		searches(cmnParam.Filter.Searches)
+	gen.Reset().filter(filter)
+	if err := gen.errs.Err(); err != nil {
+		return Selector{}, err
+	}
+	sel := NewSel(strings.Join(gen.fragments, " AND "), gen._args)
+	sel.Highlights = gen.highlights()
+	return sel, nil
+}
+
+// Conds - conditions for the filter. Unlike Selector, parameter numbering
+// continues from the previous calls so that conditions for several parts of
+// a single statement can be generated. Args gives arguments of all the calls
+func (gen *SelectorGenerator) Conds(filter *data.Filter) (string, error) {
+	if gen.dollerIndex == 0 {
+		gen.Reset()
+	}
+	gen.fragments = gen.fragments[:0]
+	gen.filter(filter)
+	if err := gen.errs.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(gen.fragments, " AND "), nil
+}
+
+// Args - arguments for the conditions generated so far
+func (gen *SelectorGenerator) Args() []any {
+	return gen._args
+}
+
+// WithKeyset - use keyset pagination, the order is given by the keyset and
+// rows after (or before when going backward) the keyset position are
+// selected. One row more than page size is selected so that the caller knows
+// if there are more rows
+func (gen *SelectorGenerator) WithKeyset(
+	ks *data.Keyset) *SelectorGenerator {
+	gen.keyset = ks
+	return gen
+}
+
+func (gen *SelectorGenerator) SelectorX(
+	cmnParam *data.CommonParams) (Selector, error) {
+
+	gen.Reset().filter(cmnParam.Filter)
 
 	buf := buffer{}
-	for idx, frag := range gen.fragments {
-		if frag == "" {
-			continue
+	if gen.keyset != nil {
+		gen.keysetCond()
+		gen.keysetOrder(&buf)
+	} else {
+		gen.order(&buf, cmnParam.SortFields())
+	}
+
+	limit, offset := cmnParam.Limit(), cmnParam.Offset()
+	if gen.keyset != nil && limit > 0 {
+		limit++
+	}
+	if gen.keyset != nil && gen.keyset.Values != nil {
+		offset = 0
+	}
+	if limit > 0 {
+		buf.write(" LIMIT $").writeInt(gen.dollerIndex)
+		gen.addArg(limit)
+		if offset > 0 {
+			buf.write(" OFFSET $").writeInt(gen.dollerIndex)
+			gen.addArg(offset)
 		}
+	}
+
+	if err := gen.errs.Err(); err != nil {
+		return Selector{}, err
+	}
+	return Selector{
+		QueryFragment: strings.Join(gen.fragments, " AND "),
+		Suffix:        buf.String(),
+		Highlights:    gen.highlights(),
+		Args:          gen._args,
+	}, nil
+}
+
+// keysetCond - condition selecting rows after the keyset position. Uses row
+// comparison when all the fields are sorted in same direction, otherwise
+// expands to (a > $1) OR (a = $1 AND b > $2) ...
+func (gen *SelectorGenerator) keysetCond() {
+	ks := gen.keyset
+	if ks.Values == nil {
+		return
+	}
 
-		buf.write(frag)
-		if idx < len(gen.fragments)-2 {
-			buf.write(" AND ")
+	cols := make([]string, 0, len(ks.Fields))
+	for _, field := range ks.Fields {
+		col, ok := gen.col(field)
+		if !ok {
+			return
 		}
+		cols = append(cols, col)
+	}
+	desc := ks.QueryDesc()
+	params := make([]string, 0, len(ks.Values))
+	for _, val := range ks.Values {
+		params = append(params, "$"+strconv.FormatInt(gen.dollerIndex, 10))
+		gen.addArg(val)
+	}
+	op := func(desc bool) string {
+		return data.Qop(desc, " < ", " > ")
 	}
 
-	if cmnParam.Limit() != 0 {
-		buf.write(" OFFSET = $").writeInt(gen.dollerIndex)
-		gen.addArg(cmnParam.Offset())
-		buf.write(" LIMIT = $").writeInt(gen.dollerIndex)
-		gen.addArg(cmnParam.Limit())
+	if !slices.Contains(desc, !desc[0]) {
+		gen.fragments = append(gen.fragments,
+			"("+strings.Join(cols, ", ")+")"+op(desc[0])+
+				"("+strings.Join(params, ", ")+")")
+		return
 	}
 
-	if cmnParam.Sort != "" {
-		buf.write(" ORDER BY $").writeInt(gen.dollerIndex)
-		gen.addArg(cmnParam.Sort)
-		buf.write(data.Qop(cmnParam.SortDescending, " DESC", " ASC"))
+	alts := make([]string, 0, len(cols))
+	for idx := range cols {
+		conds := make([]string, 0, idx+1)
+		for jdx := 0; jdx < idx; jdx++ {
+			conds = append(conds, cols[jdx]+" = "+params[jdx])
+		}
+		conds = append(conds, cols[idx]+op(desc[idx])+params[idx])
+		alts = append(alts, "("+strings.Join(conds, " AND ")+")")
 	}
+	gen.fragments = append(gen.fragments,
+		"("+strings.Join(alts, " OR ")+")")
+}
 
-	return NewSel(buf.String(), gen._args)
+func (gen *SelectorGenerator) keysetOrder(buf *buffer) {
+	desc := gen.keyset.QueryDesc()
+	for idx, field := range gen.keyset.Fields {
+		col, ok := gen.col(field)
+		if !ok {
+			return
+		}
+		buf.write(data.Qop(idx == 0, " ORDER BY ", ", ")).write(col)
+		buf.write(data.Qop(desc[idx], " DESC", " ASC"))
+	}
 }
 
-func (gen *SelectorGenerator) matchers(
-	pol map[string]*data.Matcher) *SelectorGenerator {
-	if len(pol) == 0 {
+// order - ORDER BY clause for the sort fields. Identifiers can not be bind
+// parameters, hence the validation. data.SortRank sorts by the sum of ranks
+// of full text and trigram searches, it is skipped if there are none
+func (gen *SelectorGenerator) order(buf *buffer, sorts []data.SortField) {
+	first := true
+	for _, sf := range sorts {
+		if err := sf.Validate(); err != nil {
+			gen.errs.Add(err)
+			continue
+		}
+		col := ""
+		if sf.Field == data.SortRank {
+			if len(gen.ranks) == 0 {
+				continue
+			}
+			col = "(" + strings.Join(gen.ranks, " + ") + ")"
+		} else if c, ok := gen.col(sf.Field); ok {
+			col = c
+		} else {
+			continue
+		}
+		buf.write(data.Qop(first, " ORDER BY ", ", ")).write(col)
+		first = false
+		buf.write(data.Qop(sf.Desc, " DESC", " ASC"))
+		switch sf.Nulls {
+		case data.NullsFirst:
+			buf.write(" NULLS FIRST")
+		case data.NullsLast:
+			buf.write(" NULLS LAST")
+		}
+	}
+}
+
+func (gen *SelectorGenerator) filter(filter *data.Filter) *SelectorGenerator {
+	if filter == nil {
 		return gen
 	}
+	return gen.
+		matchers(filter.Props).
+		lists(filter.Lists).
+		bools(filter.Bools).
+		dateRanges(filter.Dates).
+		ranges(filter.Ranges).
+		searches(filter.Searches)
+}
+
+// sortedKeys - map keys in sorted order so that generated queries are stable
+func sortedKeys[V any](mp map[string]V) []string {
+	keys := make([]string, 0, len(mp))
+	for key := range mp {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
 
-	buf, idx := buffer{}, 0
-	buf.Grow(100)
+func (gen *SelectorGenerator) matchers(
+	pol map[string]*data.Matcher) *SelectorGenerator {
 
-	for key, prop := range pol {
-		if idx != 0 && idx <= len(prop.Fields) {
-			buf.write(" AND ")
+	for _, key := range sortedKeys(pol) {
+		prop := pol[key]
+		if prop == nil || len(prop.Fields) == 0 {
+			continue
+		}
+		col, path, ok := gen.ref(key)
+		if !ok {
+			continue
+		}
+		if path != nil {
+			conds := make([]string, 0, len(prop.Fields))
+			for _, val := range prop.Fields {
+				conds = append(conds, gen.contains(col, path, val))
+			}
+			gen.jsonCond(col, path, prop.Invert, conds)
+			continue
 		}
 
-		buf.write(key)
+		buf := buffer{}
+		buf.write(col)
 		if prop.Invert {
-			buf.write(" NOT ")
+			buf.write(" NOT")
 		}
 		buf.write(" IN (")
 		for jdx, p := range prop.Fields {
@@ -166,119 +417,223 @@ func (gen *SelectorGenerator) matchers(
 				buf.write(", ")
 			}
 		}
-		buf.write(") ")
-		idx++
+		buf.write(")")
+		gen.fragments = append(gen.fragments, buf.String())
 	}
-	gen.fragments = append(gen.fragments, buf.String())
 	return gen
 }
 
-func (gen *SelectorGenerator) bools(
-	bools map[string]interface{}) *SelectorGenerator {
+// lists - conditions for array columns, the column must overlap with the
+// values or contain all of them if Matcher.All is set. Both the operators can
+// use GIN indexes on the column. For arrays in JSON fields, containment of
+// JSON documents with the values is checked
+func (gen *SelectorGenerator) lists(
+	lists map[string]*data.Matcher) *SelectorGenerator {
 
-	if len(bools) == 0 {
-		return gen
+	for _, key := range sortedKeys(lists) {
+		list := lists[key]
+		if list == nil || len(list.Fields) == 0 {
+			continue
+		}
+		col, path, ok := gen.ref(key)
+		if !ok {
+			continue
+		}
+
+		if path != nil {
+			conds := make([]string, 0, len(list.Fields))
+			if list.All {
+				conds = append(conds, gen.contains(col, path, list.Fields))
+			} else {
+				for _, val := range list.Fields {
+					conds = append(conds, gen.contains(col, path, []any{val}))
+				}
+			}
+			gen.jsonCond(col, path, list.Invert, conds)
+			continue
+		}
+
+		buf := buffer{}
+		buf.write(data.Qop(list.Invert, "NOT (", "(")).write(col)
+		buf.write(data.Qop(list.All, " @> $", " && $"))
+		buf.writeInt(gen.dollerIndex).write(")")
+		gen.addArg(pq.Array(list.Fields))
+		gen.fragments = append(gen.fragments, buf.String())
 	}
+	return gen
+}
 
-	buf, idx := buffer{}, 0
-	buf.Grow(100)
+// contains - JSON containment condition for the value at the path, uses
+// GIN indexes on the column
+func (gen *SelectorGenerator) contains(
+	col string, path []string, val any) string {
+	doc := val
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		doc = map[string]any{path[idx]: doc}
+	}
+	js, err := json.Marshal(doc)
+	if err != nil {
+		gen.errs.Add(errx.Errf(data.ErrInvalidFilter,
+			"invalid value for JSON key '%s'", strings.Join(path, ".")))
+		return ""
+	}
+	cond := col + " @> $" + strconv.FormatInt(gen.dollerIndex, 10) +
+		"::jsonb"
+	gen.addArg(string(js))
+	return cond
+}
 
-	for key, boolVal := range bools {
+// jsonCond - adds alternative conditions on a JSON key. Like NOT IN on a
+// column, inverted conditions do not match rows without the key
+func (gen *SelectorGenerator) jsonCond(
+	col string, path []string, invert bool, conds []string) {
+	cond := "(" + strings.Join(conds, " OR ") + ")"
+	if invert {
+		cond = "(" + jsonValue(col, path, false) + " IS NOT NULL AND NOT " +
+			cond + ")"
+	}
+	gen.fragments = append(gen.fragments, cond)
+}
 
-		if idx != 0 && idx <= len(bools)-1 {
-			buf.write(" AND ")
+// jsonValue - expression for the value at the path in a jsonb column, as
+// text if asked for. Gives the column itself if there is no path
+func jsonValue(col string, path []string, text bool) string {
+	switch len(path) {
+	case 0:
+		return col
+	case 1:
+		return "(" + col + data.Qop(text, " ->> ", " -> ") +
+			pq.QuoteLiteral(path[0]) + ")"
+	}
+	return "(" + col + data.Qop(text, " #>> ", " #> ") +
+		pq.QuoteLiteral("{"+strings.Join(path, ",")+"}") + ")"
+}
+
+func (gen *SelectorGenerator) bools(
+	bools map[string]interface{}) *SelectorGenerator {
+
+	for _, key := range sortedKeys(bools) {
+		boolVal := bools[key]
+		if boolVal == nil {
+			continue
+		}
+		col, path, ok := gen.ref(key)
+		if !ok {
+			continue
+		}
+		if path != nil {
+			gen.fragments = append(gen.fragments,
+				gen.contains(col, path, boolVal))
+			continue
 		}
 
-		buf.write(key).
+		buf := buffer{}
+		buf.write(col).
 			write(" = $").
 			writeInt(gen.dollerIndex)
 		gen.addArg(boolVal) // :P
-		idx++
+		gen.fragments = append(gen.fragments, buf.String())
 	}
-	gen.fragments = append(gen.fragments, buf.String())
 	return gen
 }
 
-func (gen *SelectorGenerator) dateRanges(
-	dates map[string]*data.DateRangeMatcher) *SelectorGenerator {
-	if len(dates) == 0 {
-		return gen
+func (gen *SelectorGenerator) between(
+	key string, invert bool, from, to any) {
+	col, path, ok := gen.ref(key)
+	if !ok {
+		return
 	}
-	buf, idx := buffer{}, 0
-	buf.Grow(100)
-
-	for key, dt := range dates {
-
-		if idx != 0 && idx <= len(dates)-1 {
-			buf.write(" AND ")
+	if path != nil {
+		if _, isNum := from.(float64); isNum {
+			gen.jsonBetween(col, path, invert, from, to)
+			return
 		}
+		// Dates in JSON are strings, jsonpath datetime() does not take
+		// all the RFC 3339 forms, hence the cast
+		col = jsonValue(col, path, true) + "::timestamptz"
+	}
 
-		buf.write("(")
-		buf.write(key)
-		if dt.Invert {
-			buf.write(" NOT")
-		}
-		buf.write(" BETWEEN $").writeInt(gen.dollerIndex)
-		gen.addArg(dt.From)
-		buf.write(" AND $").writeInt(gen.dollerIndex)
-		gen.addArg(dt.To)
-		buf.write(")")
-
-		idx++
+	buf := buffer{}
+	buf.write("(").write(col)
+	if invert {
+		buf.write(" NOT")
 	}
+	buf.write(" BETWEEN $").writeInt(gen.dollerIndex)
+	gen.addArg(from)
+	buf.write(" AND $").writeInt(gen.dollerIndex)
+	gen.addArg(to)
+	buf.write(")")
 	gen.fragments = append(gen.fragments, buf.String())
-	return gen
 }
 
-func (gen *SelectorGenerator) ranges(
-	ranges map[string]*data.RangeMatcher) *SelectorGenerator {
-	if len(ranges) == 0 {
-		return gen
+// jsonBetween - number range condition on a JSON key using jsonpath, values
+// that are not numbers do not match instead of failing the query
+func (gen *SelectorGenerator) jsonBetween(
+	col string, path []string, invert bool, from, to any) {
+	jpath := "$"
+	for _, key := range path {
+		jpath += `."` + key + `"`
 	}
+	jpath += data.Qop(invert,
+		" ? (@ < $from || @ > $to)", " ? (@ >= $from && @ <= $to)")
 
-	buf, idx := buffer{}, 0
-	buf.Grow(100)
-
-	for key, dt := range ranges {
+	buf := buffer{}
+	buf.write("jsonb_path_exists(").write(col).write(", ")
+	buf.write(pq.QuoteLiteral(jpath)).write(", jsonb_build_object(")
+	buf.write("'from', $").writeInt(gen.dollerIndex).write("::float8, ")
+	gen.addArg(from)
+	buf.write("'to', $").writeInt(gen.dollerIndex).write("::float8))")
+	gen.addArg(to)
+	gen.fragments = append(gen.fragments, buf.String())
+}
 
-		if idx != 0 && idx <= len(ranges)-1 {
-			buf.write(" AND ")
+func (gen *SelectorGenerator) dateRanges(
+	dates map[string]*data.DateRangeMatcher) *SelectorGenerator {
+	for _, key := range sortedKeys(dates) {
+		if dt := dates[key]; dt != nil {
+			gen.between(key, dt.Invert, dt.From, dt.To)
 		}
+	}
+	return gen
+}
 
-		buf.write("(")
-		buf.write(key)
-		if dt.Invert {
-			buf.write(" NOT")
+func (gen *SelectorGenerator) ranges(
+	ranges map[string]*data.RangeMatcher) *SelectorGenerator {
+	for _, key := range sortedKeys(ranges) {
+		if rg := ranges[key]; rg != nil {
+			gen.between(key, rg.Invert, rg.From, rg.To)
 		}
-		buf.write(" BETWEEN $").writeInt(gen.dollerIndex)
-		gen.addArg(dt.From)
-		buf.write(" AND $").writeInt(gen.dollerIndex)
-		gen.addArg(dt.To)
-		buf.write(")")
-
-		idx++
 	}
-	gen.fragments = append(gen.fragments, buf.String())
 	return gen
 }
 
 func (gen *SelectorGenerator) searches(
 	searches map[string]*data.Matcher) *SelectorGenerator {
-	buf, idx := buffer{}, 0
-	buf.Grow(100)
 
-	for key, prop := range searches {
-		if len(prop.Fields) == 0 {
+	for _, key := range sortedKeys(searches) {
+		prop := searches[key]
+		if prop == nil || len(prop.Fields) == 0 {
 			continue
 		}
+		col, path, ok := gen.ref(key)
+		if !ok {
+			continue
+		}
+		col = jsonValue(col, path, true)
 
-		if idx != 0 && idx <= len(searches)-1 {
-			buf.write(" AND ")
+		var ts *data.TextSearch
+		if spec := gen.specs[key]; spec != nil {
+			ts = spec.Search
+		}
+		if ts != nil && ts.Mode != data.SearchPattern {
+			gen.textSearch(key, col, ts, prop)
+			continue
 		}
 
+		buf := buffer{}
 		buf.write("(")
 		for jdx, p := range prop.Fields {
-			buf.write(key)
+			buf.write(col)
 			if prop.Invert {
 				buf.write(" NOT")
 			}
@@ -287,18 +642,102 @@ func (gen *SelectorGenerator) searches(
 				writeInt(gen.dollerIndex)
 			gen.addArg(p)
 			if jdx < len(prop.Fields)-1 {
-				buf.write(" OR ")
+				buf.write(data.Qop(prop.Invert, " AND ", " OR "))
 			}
 		}
 		buf.write(")")
-
-		idx++
+		gen.fragments = append(gen.fragments, buf.String())
 	}
-	fragment := buf.String()
-	gen.fragments = append(gen.fragments, fragment)
 	return gen
 }
 
+// textSearch - full text or trigram search condition for the field, records
+// rank and headline expressions for non-inverted searches. The bind
+// parameters of the condition are reused in those expressions
+func (gen *SelectorGenerator) textSearch(
+	field, col string, ts *data.TextSearch, prop *data.Matcher) {
+
+	params := make([]string, 0, len(prop.Fields))
+	for _, p := range prop.Fields {
+		params = append(params, "$"+strconv.FormatInt(gen.dollerIndex, 10))
+		gen.addArg(p)
+	}
+
+	conds := make([]string, 0, len(params))
+	rank, headline := "", ""
+	switch ts.Mode {
+	case data.SearchFullText:
+		cfg := ts.TextConfig()
+		if !identRx.MatchString(cfg) {
+			gen.errs.Add(errx.Errf(data.ErrInvalidFilter,
+				"invalid text search config '%s' for '%s'", cfg, field))
+			return
+		}
+		// Config is inlined so that expression indexes can be used
+		cfg = pq.QuoteLiteral(cfg) + "::regconfig"
+		vec := "to_tsvector(" + cfg + ", " + col + ")"
+		if ts.Vector != "" {
+			if !identRx.MatchString(ts.Vector) {
+				gen.errs.Add(errx.Errf(data.ErrInvalidFilter,
+					"invalid tsvector column '%s' for '%s'",
+					ts.Vector, field))
+				return
+			}
+			vec = QuoteIdent(ts.Vector)
+		}
+
+		queries := make([]string, 0, len(params))
+		for _, param := range params {
+			query := "websearch_to_tsquery(" + cfg + ", " + param + ")"
+			queries = append(queries, query)
+			conds = append(conds, vec+" @@ "+query)
+		}
+		query := strings.Join(queries, " || ")
+		rank = "coalesce(ts_rank(" + vec + ", " + query + "), 0)"
+		headline = pq.QuoteLiteral(field) +
+			", ts_headline(" + cfg + ", " + col + ", " + query + ")"
+
+	case data.SearchTrigram:
+		sims := make([]string, 0, len(params))
+		for _, param := range params {
+			sim := "similarity(" + col + ", " + param + ")"
+			sims = append(sims, sim)
+			if ts.Threshold > 0 {
+				conds = append(conds, sim+" >= "+
+					strconv.FormatFloat(ts.Threshold, 'f', -1, 64))
+			} else {
+				conds = append(conds, col+" % "+param)
+			}
+		}
+		rank = "coalesce(greatest(" + strings.Join(sims, ", ") + "), 0)"
+
+	default:
+		gen.errs.Add(errx.Errf(data.ErrInvalidFilter,
+			"invalid search mode '%s' for '%s'", ts.Mode, field))
+		return
+	}
+
+	cond := "(" + strings.Join(conds, " OR ") + ")"
+	if prop.Invert {
+		// Rank and highlights of rows that do not match are meaningless
+		gen.fragments = append(gen.fragments, "NOT "+cond)
+		return
+	}
+	gen.fragments = append(gen.fragments, cond)
+	gen.ranks = append(gen.ranks, rank)
+	if headline != "" {
+		gen.headlines = append(gen.headlines, headline)
+	}
+}
+
+// highlights - JSON object expression with headlines of full text searches
+func (gen *SelectorGenerator) highlights() string {
+	if len(gen.headlines) == 0 {
+		return ""
+	}
+	return "jsonb_build_object(" + strings.Join(gen.headlines, ", ") + ")"
+}
+
 func (gen *SelectorGenerator) addArg(arg interface{}) *SelectorGenerator {
 	gen._args = append(gen._args, arg)
 	gen.dollerIndex++
@@ -0,0 +1,307 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/data"
+)
+
+// DefaultSlowQuery - queries taking longer are logged as slow by tracers
+// created with NewTracer
+const DefaultSlowQuery = 500 * time.Millisecond
+
+// QueryInfo - a query run through a traced querier, given to hooks
+type QueryInfo struct {
+	// Op - one of exec, query, get and select
+	Op    string
+	Query string
+	Args  []any
+	// InTx - true if the query ran in a transaction, see WithTx
+	InTx  bool
+	Start time.Time
+	// Duration - time taken by the query, it is set before AfterQuery hooks
+	// are called. For op query, it does not include reading the rows
+	Duration time.Duration
+	// Rows - rows affected for exec, rows read for get and select and -1 for
+	// query since the rows are read by the caller
+	Rows int64
+	Err  error
+}
+
+// BeforeQuery - hook called before a query runs. The returned context is
+// used for the query and is given to AfterQuery hooks, such as one carrying
+// a tracing span
+type BeforeQuery func(gtx context.Context, qi *QueryInfo) context.Context
+
+// AfterQuery - hook called after a query returns
+type AfterQuery func(gtx context.Context, qi *QueryInfo)
+
+// Redactor - gives the query arguments as they should appear in logs
+type Redactor func(query string, args []any) []any
+
+// RedactAll - replaces all the arguments with a placeholder
+func RedactAll(_ string, args []any) []any {
+	out := make([]any, len(args))
+	for idx := range args {
+		out[idx] = "<redacted>"
+	}
+	return out
+}
+
+// RedactNone - logs the arguments as they are
+func RedactNone(_ string, args []any) []any {
+	return args
+}
+
+// Tracer - logs queries at trace level, logs queries slower than the
+// threshold as warnings and calls the hooks around queries. Set it with
+// SetTracer for queries run by this package, or use Traced to wrap a
+// database or a transaction
+type Tracer struct {
+	// SlowThreshold - queries taking longer are logged as warnings, 0
+	// disables slow query logging
+	SlowThreshold time.Duration
+	// Explain - query plans of slow queries are logged if debug logging is
+	// enabled. The plan is got using EXPLAIN without ANALYZE, so the query
+	// is not run again. Queries in transactions are not explained, a failed
+	// EXPLAIN would abort the transaction
+	Explain bool
+	// Redact - policy for arguments in logs, RedactAll is used if nil.
+	// Hooks get the arguments as they are
+	Redact Redactor
+
+	before []BeforeQuery
+	after  []AfterQuery
+}
+
+// NewTracer - tracer with DefaultSlowQuery threshold that redacts all the
+// arguments in logs
+func NewTracer() *Tracer {
+	return &Tracer{
+		SlowThreshold: DefaultSlowQuery,
+		Redact:        RedactAll,
+	}
+}
+
+// Before - adds a hook called before queries, hooks are called in the order
+// they are added. Hooks should be added before the tracer is used
+func (t *Tracer) Before(hook BeforeQuery) *Tracer {
+	t.before = append(t.before, hook)
+	return t
+}
+
+// After - adds a hook called after queries, hooks are called in the order
+// they are added. Hooks should be added before the tracer is used
+func (t *Tracer) After(hook AfterQuery) *Tracer {
+	t.after = append(t.after, hook)
+	return t
+}
+
+var defTracer atomic.Pointer[Tracer]
+
+// SetTracer - sets the tracer for queries run through ConnFor and clusters,
+// which covers the getter-deleter, repositories and rest of this package.
+// Nil disables tracing
+func SetTracer(t *Tracer) {
+	defTracer.Store(t)
+}
+
+// Traced - wraps the querier, such as a *sqlx.DB or a *sqlx.Tx, so that
+// queries run through it are traced by the tracer
+func Traced(q Querier, t *Tracer) Querier {
+	_, inTx := q.(*sqlx.Tx)
+	return &tracedQuerier{q: q, tracer: t, inTx: inTx}
+}
+
+// traced - wraps the querier with the tracer set by SetTracer if there is
+// one
+func traced(q Querier) Querier {
+	if t := defTracer.Load(); t != nil {
+		return Traced(q, t)
+	}
+	return q
+}
+
+type tracedQuerier struct {
+	q      Querier
+	tracer *Tracer
+	inTx   bool
+}
+
+func (tq *tracedQuerier) run(
+	gtx context.Context,
+	op, query string,
+	args []any,
+	fn func(gtx context.Context) (int64, error)) {
+	t := tq.tracer
+	qi := &QueryInfo{
+		Op:    op,
+		Query: query,
+		Args:  args,
+		InTx:  tq.inTx,
+		Rows:  -1,
+	}
+	for _, hook := range t.before {
+		gtx = hook(gtx, qi)
+	}
+
+	qi.Start = time.Now()
+	qi.Rows, qi.Err = fn(gtx)
+	qi.Duration = time.Since(qi.Start)
+
+	t.log(gtx, tq.q, qi)
+	for _, hook := range t.after {
+		hook(gtx, qi)
+	}
+}
+
+func (t *Tracer) log(gtx context.Context, q Querier, qi *QueryInfo) {
+	redact := t.Redact
+	if redact == nil {
+		redact = RedactAll
+	}
+
+	slow := t.SlowThreshold > 0 && qi.Duration > t.SlowThreshold
+	evt := log.Trace()
+	if slow {
+		evt = log.Warn()
+	}
+	if !evt.Enabled() {
+		return
+	}
+	evt.Str("op", qi.Op).
+		Str("query", qi.Query).
+		Interface("args", redact(qi.Query, qi.Args)).
+		Bool("inTx", qi.InTx).
+		Dur("duration", qi.Duration).
+		Int64("rows", qi.Rows).
+		Err(qi.Err).
+		Msg(data.Qop(slow, "slow query", "query"))
+
+	if slow && t.Explain && !qi.InTx && qi.Err == nil &&
+		explainable(qi.Query) && log.Debug().Enabled() {
+		plan := make([]string, 0, 10)
+		err := q.SelectContext(gtx, &plan, "EXPLAIN "+qi.Query, qi.Args...)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to explain slow query")
+			return
+		}
+		log.Debug().
+			Str("query", qi.Query).
+			Str("plan", strings.Join(plan, "\n")).
+			Msg("plan of slow query")
+	}
+}
+
+// explainable - checks if EXPLAIN can be used with the statement
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "INSERT", "UPDATE", "DELETE", "VALUES":
+		return true
+	}
+	return false
+}
+
+func (tq *tracedQuerier) DriverName() string {
+	return tq.q.DriverName()
+}
+
+func (tq *tracedQuerier) Rebind(query string) string {
+	return tq.q.Rebind(query)
+}
+
+func (tq *tracedQuerier) BindNamed(
+	query string, arg any) (string, []any, error) {
+	return tq.q.BindNamed(query, arg)
+}
+
+func (tq *tracedQuerier) QueryContext(
+	gtx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	var err error
+	tq.run(gtx, "query", query, args,
+		func(gtx context.Context) (int64, error) {
+			rows, err = tq.q.QueryContext(gtx, query, args...)
+			return -1, err
+		})
+	return rows, err
+}
+
+func (tq *tracedQuerier) QueryxContext(
+	gtx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	var rows *sqlx.Rows
+	var err error
+	tq.run(gtx, "query", query, args,
+		func(gtx context.Context) (int64, error) {
+			rows, err = tq.q.QueryxContext(gtx, query, args...)
+			return -1, err
+		})
+	return rows, err
+}
+
+func (tq *tracedQuerier) QueryRowxContext(
+	gtx context.Context, query string, args ...any) *sqlx.Row {
+	var row *sqlx.Row
+	tq.run(gtx, "query", query, args,
+		func(gtx context.Context) (int64, error) {
+			row = tq.q.QueryRowxContext(gtx, query, args...)
+			return -1, row.Err()
+		})
+	return row
+}
+
+func (tq *tracedQuerier) ExecContext(
+	gtx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	var err error
+	tq.run(gtx, "exec", query, args,
+		func(gtx context.Context) (int64, error) {
+			res, err = tq.q.ExecContext(gtx, query, args...)
+			if err != nil {
+				return -1, err
+			}
+			affected, rerr := res.RowsAffected()
+			if rerr != nil {
+				return -1, nil
+			}
+			return affected, nil
+		})
+	return res, err
+}
+
+func (tq *tracedQuerier) GetContext(
+	gtx context.Context, dest any, query string, args ...any) error {
+	var err error
+	tq.run(gtx, "get", query, args,
+		func(gtx context.Context) (int64, error) {
+			if err = tq.q.GetContext(gtx, dest, query, args...); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		})
+	return err
+}
+
+func (tq *tracedQuerier) SelectContext(
+	gtx context.Context, dest any, query string, args ...any) error {
+	var err error
+	tq.run(gtx, "select", query, args,
+		func(gtx context.Context) (int64, error) {
+			if err = tq.q.SelectContext(gtx, dest, query, args...); err != nil {
+				return 0, err
+			}
+			return int64(reflect.Indirect(reflect.ValueOf(dest)).Len()), nil
+		})
+	return err
+}
@@ -0,0 +1,202 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/errx"
+)
+
+// DefaultTxRetries - number of times a transaction is retried on
+// serialization failures and deadlocks if TxOpts.MaxRetries is not given
+const DefaultTxRetries = 3
+
+// DefaultTxBackoff - delay before the first retry of a transaction, it is
+// doubled for each subsequent retry
+const DefaultTxBackoff = 50 * time.Millisecond
+
+// Querier - methods common to sqlx.DB and sqlx.Tx that are used for queries
+type Querier interface {
+	sqlx.ExtContext
+	GetContext(gtx context.Context, dest any, query string, args ...any) error
+	SelectContext(
+		gtx context.Context, dest any, query string, args ...any) error
+}
+
+// TxOpts - options for transactions started by WithTx
+type TxOpts struct {
+	sql.TxOptions
+
+	// DB - database to start the transaction on, the default connection is
+	// used if nil
+	DB *sqlx.DB
+
+	// MaxRetries - number of retries on serialization failures and
+	// deadlocks, DefaultTxRetries is used if 0 and a negative value disables
+	// retries
+	MaxRetries int
+
+	// Backoff - delay before the first retry, DefaultTxBackoff is used if 0
+	Backoff time.Duration
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx    *sqlx.Tx
+	db    *sqlx.DB
+	depth int
+}
+
+// WithTx - runs fn in a transaction that is committed if fn succeeds and
+// rolled back otherwise. The transaction is carried by the context given to
+// fn, the getter-deleter, repositories and ConnFor use it for queries on the
+// same database. Nested calls with such a context use savepoints, only the
+// work of the failed nested call is rolled back. On serialization failures
+// and deadlocks (SQLSTATE 40001/40P01), the outermost call retries the whole
+// transaction with exponential backoff, so fn must not have side effects
+// outside the database. The transaction must not be used concurrently
+func WithTx(
+	gtx context.Context,
+	opts *TxOpts,
+	fn func(gtx context.Context) error) error {
+	if opts == nil {
+		opts = &TxOpts{}
+	}
+	db := opts.DB
+	if db == nil {
+		db = defDB
+	}
+	if st, ok := gtx.Value(txKey{}).(*txState); ok && st.db == db {
+		return savepoint(gtx, st, fn)
+	}
+
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = DefaultTxRetries
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = DefaultTxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(gtx, db, &opts.TxOptions, fn)
+		if err == nil || attempt >= retries || !isTxConflict(err) {
+			return err
+		}
+
+		delay := backoff << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		log.Warn().Err(err).
+			Int("attempt", attempt+1).
+			Dur("delay", delay).
+			Msg("retrying transaction")
+		select {
+		case <-gtx.Done():
+			return errx.Errf(err, "transaction not retried: %v", gtx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// TxFrom - transaction carried by the context, nil if there is none. See
+// WithTx
+func TxFrom(gtx context.Context) *sqlx.Tx {
+	if st, ok := gtx.Value(txKey{}).(*txState); ok {
+		return st.tx
+	}
+	return nil
+}
+
+// ConnFor - querier for the database, it is the transaction carried by the
+// context if there is one on the database. The default connection is used if
+// db is nil. Queries are traced if a tracer is set, see SetTracer
+func ConnFor(gtx context.Context, db *sqlx.DB) Querier {
+	if db == nil {
+		db = defDB
+	}
+	if st, ok := gtx.Value(txKey{}).(*txState); ok && st.db == db {
+		return traced(st.tx)
+	}
+	return traced(db)
+}
+
+func runTx(
+	gtx context.Context,
+	db *sqlx.DB,
+	txOpts *sql.TxOptions,
+	fn func(gtx context.Context) error) error {
+	tx, err := db.BeginTxx(gtx, txOpts)
+	if err != nil {
+		return errx.Errf(err, "failed to start transaction")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			Rollback("withTx", tx)
+			panic(p)
+		}
+	}()
+
+	st := &txState{tx: tx, db: db}
+	if err := fn(context.WithValue(gtx, txKey{}, st)); err != nil {
+		Rollback("withTx", tx)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errx.Errf(err, "failed to commit transaction")
+	}
+	return nil
+}
+
+func savepoint(
+	gtx context.Context,
+	st *txState,
+	fn func(gtx context.Context) error) error {
+	st.depth++
+	defer func() { st.depth-- }()
+
+	name := "libx_sp_" + strconv.Itoa(st.depth)
+	if _, err := st.tx.ExecContext(gtx, "SAVEPOINT "+name); err != nil {
+		return errx.Errf(err, "failed to create savepoint")
+	}
+	rollback := func() {
+		_, err := st.tx.ExecContext(gtx, "ROLLBACK TO SAVEPOINT "+name)
+		if err != nil {
+			log.Error().Err(err).Str("savepoint", name).
+				Msg("failed to rollback to savepoint")
+		}
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(gtx); err != nil {
+		rollback()
+		return err
+	}
+	if _, err := st.tx.ExecContext(gtx, "RELEASE SAVEPOINT "+name); err != nil {
+		return errx.Errf(err, "failed to release savepoint")
+	}
+	return nil
+}
+
+// isTxConflict - checks if the error is a serialization failure or a
+// deadlock, these are resolved by retrying the transaction
+func isTxConflict(err error) bool {
+	var sse interface{ SQLState() string }
+	if !errors.As(err, &sse) {
+		return false
+	}
+	state := sse.SQLState()
+	return state == "40001" || state == "40P01"
+}
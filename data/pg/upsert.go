@@ -0,0 +1,270 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/varunamachi/libx/data"
+	"github.com/varunamachi/libx/errx"
+)
+
+// UpsertQuery - like InsertQuery, but updates the existing row if a row with
+// same values for the conflict fields exists. Rest of the fields are updated
+// with the values being inserted
+func UpsertQuery(table string, conflict []string, fields ...string) string {
+	builder := strings.Builder{}
+	builder.WriteString(InsertQuery(table, fields...))
+	builder.WriteString(" ON CONFLICT (")
+	builder.WriteString(strings.Join(conflict, ", "))
+	builder.WriteString(")")
+
+	update := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !slices.Contains(conflict, field) {
+			update = append(update, field+" = EXCLUDED."+field)
+		}
+	}
+	if len(update) == 0 {
+		builder.WriteString(" DO NOTHING")
+		return builder.String()
+	}
+	builder.WriteString(" DO UPDATE SET ")
+	builder.WriteString(strings.Join(update, ", "))
+	return builder.String()
+}
+
+// Statement - a query with its arguments
+type Statement struct {
+	Query string
+	Args  []any
+}
+
+// BatchInsert - builds multi row INSERT statements with optional ON CONFLICT
+// and RETURNING clauses. Rows are split into batches so that no statement
+// has more parameters than postgres allows. Identifiers are quoted
+//
+//	err := pg.NewBatchInsert("app_user", "email", "name").
+//		Row("a@x.com", "A").
+//		Row("b@x.com", "B").
+//		OnConflict("email").
+//		DoUpdate("name").
+//		Returning("id").
+//		Select(gtx, &ids)
+type BatchInsert struct {
+	table      string
+	cols       []string
+	rows       [][]any
+	conflict   []string
+	constraint string
+	update     []string
+	doUpdate   bool
+	doNothing  bool
+	returning  []string
+	errs       errx.Multi
+}
+
+// NewBatchInsert - batch insert into the table with given columns
+func NewBatchInsert(table string, cols ...string) *BatchInsert {
+	return &BatchInsert{
+		table: table,
+		cols:  cols,
+		rows:  make([][]any, 0, 100),
+	}
+}
+
+// Row - adds a row, values must be in the order of the columns
+func (bi *BatchInsert) Row(vals ...any) *BatchInsert {
+	if len(vals) != len(bi.cols) {
+		bi.errs.Add(errx.Fmt("expected %d values for a row of '%s', got %d",
+			len(bi.cols), bi.table, len(vals)))
+		return bi
+	}
+	bi.rows = append(bi.rows, vals)
+	return bi
+}
+
+// OnConflict - columns of the unique index or constraint that decides if a
+// row conflicts with an existing row
+func (bi *BatchInsert) OnConflict(cols ...string) *BatchInsert {
+	bi.conflict = cols
+	return bi
+}
+
+// OnConstraint - name of the unique or exclusion constraint that decides if
+// a row conflicts with an existing row
+func (bi *BatchInsert) OnConstraint(name string) *BatchInsert {
+	bi.constraint = name
+	return bi
+}
+
+// DoUpdate - columns of the existing row updated with the values of the
+// conflicting row. If no columns are given, all the columns except those of
+// the conflict target are updated
+func (bi *BatchInsert) DoUpdate(cols ...string) *BatchInsert {
+	bi.doUpdate, bi.update = true, cols
+	return bi
+}
+
+// DoNothing - conflicting rows are skipped
+func (bi *BatchInsert) DoNothing() *BatchInsert {
+	bi.doNothing = true
+	return bi
+}
+
+// Returning - columns of the inserted or updated rows to return
+func (bi *BatchInsert) Returning(cols ...string) *BatchInsert {
+	bi.returning = cols
+	return bi
+}
+
+// Statements - statements for the batches
+func (bi *BatchInsert) Statements() ([]Statement, error) {
+	if err := bi.errs.Err(); err != nil {
+		return nil, err
+	}
+	if len(bi.cols) == 0 {
+		return nil, errx.Fmt("no columns given for insert into '%s'",
+			bi.table)
+	}
+	if len(bi.cols) > maxParams {
+		return nil, errx.Fmt("%d columns given for insert into '%s', at most "+
+			"%d are allowed", len(bi.cols), bi.table, maxParams)
+	}
+	suffix, err := bi.suffix()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "INSERT INTO " + QuoteIdent(bi.table) + " (" +
+		strings.Join(quoteAll(bi.cols), ", ") + ") VALUES "
+	batchSize := maxParams / len(bi.cols)
+	stmts := make([]Statement, 0, len(bi.rows)/batchSize+1)
+	for start := 0; start < len(bi.rows); start += batchSize {
+		end := min(start+batchSize, len(bi.rows))
+		buf := buffer{}
+		buf.write(prefix)
+		args := make([]any, 0, (end-start)*len(bi.cols))
+		for idx, row := range bi.rows[start:end] {
+			buf.write(data.Qop(idx == 0, "(", ", ("))
+			for jdx, val := range row {
+				buf.write(data.Qop(jdx == 0, "$", ", $"))
+				args = append(args, val)
+				buf.writeInt(int64(len(args)))
+			}
+			buf.write(")")
+		}
+		buf.write(suffix)
+		stmts = append(stmts, Statement{Query: buf.String(), Args: args})
+	}
+	return stmts, nil
+}
+
+func (bi *BatchInsert) suffix() (string, error) {
+	buf := buffer{}
+	if bi.doUpdate || bi.doNothing {
+		buf.write(" ON CONFLICT")
+		switch {
+		case bi.constraint != "":
+			buf.write(" ON CONSTRAINT ").write(QuoteIdent(bi.constraint))
+		case len(bi.conflict) != 0:
+			buf.write(" (")
+			buf.write(strings.Join(quoteAll(bi.conflict), ", "))
+			buf.write(")")
+		case bi.doUpdate:
+			return "", errx.Fmt(
+				"conflict target is required to update rows of '%s'",
+				bi.table)
+		}
+	}
+
+	update := bi.update
+	if bi.doUpdate && len(update) == 0 {
+		for _, col := range bi.cols {
+			if !slices.Contains(bi.conflict, col) {
+				update = append(update, col)
+			}
+		}
+	}
+	switch {
+	case bi.doNothing || (bi.doUpdate && len(update) == 0):
+		buf.write(" DO NOTHING")
+	case bi.doUpdate:
+		buf.write(" DO UPDATE SET ")
+		for idx, col := range update {
+			col = QuoteIdent(col)
+			buf.write(data.Qop(idx == 0, "", ", "))
+			buf.write(col).write(" = EXCLUDED.").write(col)
+		}
+	}
+
+	if len(bi.returning) != 0 {
+		buf.write(" RETURNING ")
+		buf.write(strings.Join(quoteAll(bi.returning), ", "))
+	}
+	return buf.String(), nil
+}
+
+// Exec - runs the statements in a transaction, the one carried by the
+// context is used if there is one. Returns number of rows inserted or
+// updated
+func (bi *BatchInsert) Exec(gtx context.Context) (int64, error) {
+	stmts, err := bi.Statements()
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(0)
+	err = WithTx(gtx, nil, func(gtx context.Context) error {
+		count = 0
+		for _, stmt := range stmts {
+			res, err := ConnFor(gtx, nil).ExecContext(
+				gtx, stmt.Query, stmt.Args...)
+			if err != nil {
+				return errx.Errf(err, "failed to insert into '%s'",
+					bi.table)
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return errx.Errf(err, "failed to get affected row count")
+			}
+			count += affected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Select - runs the statements in a transaction like Exec and reads rows
+// given by the RETURNING clause into out, which must be a pointer to slice
+func (bi *BatchInsert) Select(gtx context.Context, out any) error {
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.Elem().Kind() != reflect.Slice {
+		return errx.Fmt("expected a pointer to slice, got '%T'", out)
+	}
+	stmts, err := bi.Statements()
+	if err != nil {
+		return err
+	}
+
+	slice := outVal.Elem()
+	return WithTx(gtx, nil, func(gtx context.Context) error {
+		all := reflect.MakeSlice(slice.Type(), 0, len(bi.rows))
+		for _, stmt := range stmts {
+			batch := reflect.New(slice.Type())
+			err := ConnFor(gtx, nil).SelectContext(
+				gtx, batch.Interface(), stmt.Query, stmt.Args...)
+			if err != nil {
+				return errx.Errf(err, "failed to insert into '%s'",
+					bi.table)
+			}
+			all = reflect.AppendSlice(all, batch.Elem())
+		}
+		slice.Set(all)
+		return nil
+	})
+}
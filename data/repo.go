@@ -0,0 +1,313 @@
+package data
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidModel = errors.New("data.model.invalid")
+	ErrUnknownField = errx.Declare(
+		"data.model.unknownField", http.StatusBadRequest,
+		"Unknown Field", "field is not part of the data model")
+	ErrNotFound = errx.Declare(
+		"data.item.notFound", http.StatusNotFound,
+		"Not Found", "requested item does not exist")
+)
+
+// Repository - typed access to items of type T stored in a table or a
+// collection. Description of the storage is derived from struct tags of T,
+// see NewModelInfo
+type Repository[T any] interface {
+	Info() *ModelInfo
+
+	Get(gtx context.Context, key any) (*T, error)
+	List(gtx context.Context, params *CommonParams) ([]T, error)
+	// ListPage - lists a page of items along with cursors to adjacent pages,
+	// keyset pagination is used if the params have a cursor
+	ListPage(gtx context.Context, params *CommonParams) (*Page[T], error)
+	Count(gtx context.Context, filter *Filter) (int64, error)
+	Exists(gtx context.Context, key any) (bool, error)
+	FilterValues(
+		gtx context.Context,
+		specs []*FilterSpec,
+		filter *Filter) (*FilterValues, error)
+
+	Insert(gtx context.Context, item *T) error
+	BulkInsert(gtx context.Context, items []*T) error
+	// Update - replaces all the fields of existing item with same key
+	Update(gtx context.Context, item *T) error
+	// UpdateFields - updates only the given fields of the item with given key,
+	// the map is keyed by column names
+	UpdateFields(gtx context.Context, key any, fields map[string]any) error
+	// Upsert - inserts the item or replaces the existing item with same key
+	Upsert(gtx context.Context, item *T) error
+	Delete(gtx context.Context, keys ...any) error
+}
+
+// Column - a field of data model that is stored
+type Column struct {
+	Name  string
+	Key   bool
+	Auto  bool
+	index []int
+}
+
+// ModelInfo - storage description of a struct type
+type ModelInfo struct {
+	Name    string
+	Key     *Column
+	Columns []*Column
+	byName  map[string]*Column
+	rtype   reflect.Type
+}
+
+// NewModelInfo - describes type T using given struct tag ('db' or 'bson').
+// The tag value is the column name, fields tagged '-' are skipped. Options
+// after the name mark the key field ('key') and whether its value is generated
+// by the database ('auto'):
+//
+//	type User struct {
+//		_    struct{} `db:"app_user" bson:"users"`
+//		Id   int      `db:"id,key,auto" bson:"_id,key"`
+//		Name string   `db:"name" bson:"name"`
+//	}
+//
+// The table or collection name is taken from the tag of a blank field, if
+// there is none snake cased type name is used. If no field is marked as key,
+// 'id' (or '_id' for bson) is used
+func NewModelInfo[T any](tag string) (*ModelInfo, error) {
+	rtype := reflect.TypeOf((*T)(nil)).Elem()
+	if rtype.Kind() != reflect.Struct {
+		return nil, errx.Errf(ErrInvalidModel,
+			"data model '%s' is not a struct", rtype)
+	}
+
+	mi := &ModelInfo{
+		Name:   toSnakeCase(rtype.Name()),
+		byName: map[string]*Column{},
+		rtype:  rtype,
+	}
+	mi.collect(rtype, tag, nil)
+
+	if mi.Key == nil {
+		defKey := "id"
+		if tag == "bson" {
+			defKey = "_id"
+		}
+		if col := mi.byName[defKey]; col != nil {
+			col.Key = true
+			mi.Key = col
+		}
+	}
+	if mi.Key == nil {
+		return nil, errx.Errf(ErrInvalidModel,
+			"no key field found in data model '%s'", rtype)
+	}
+	if mi.Name == "" {
+		return nil, errx.Errf(ErrInvalidModel,
+			"could not find table/collection name for '%s'", rtype)
+	}
+	return mi, nil
+}
+
+func (mi *ModelInfo) collect(rtype reflect.Type, tag string, index []int) {
+	for i := 0; i < rtype.NumField(); i++ {
+		sf := rtype.Field(i)
+		tagVal, hasTag := sf.Tag.Lookup(tag)
+		name, opts, _ := strings.Cut(tagVal, ",")
+
+		if sf.Name == "_" {
+			if name != "" {
+				mi.Name = name
+			}
+			continue
+		}
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct &&
+			(!hasTag || strings.Contains(opts, "inline")) {
+			mi.collect(sf.Type, tag, fieldIndex)
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		col := &Column{
+			Name:  name,
+			index: fieldIndex,
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "key":
+				col.Key = true
+			case "auto":
+				col.Auto = true
+			}
+		}
+		if col.Key && mi.Key == nil {
+			mi.Key = col
+		}
+		mi.Columns = append(mi.Columns, col)
+		mi.byName[name] = col
+	}
+}
+
+// Column - gives the column with given name, nil if there is no such column
+func (mi *ModelInfo) Column(name string) *Column {
+	return mi.byName[name]
+}
+
+// Field - struct field that is stored in the column
+func (mi *ModelInfo) Field(col *Column) reflect.StructField {
+	return mi.rtype.FieldByIndex(col.index)
+}
+
+// ColumnNames - names of all the columns
+func (mi *ModelInfo) ColumnNames() []string {
+	names := make([]string, 0, len(mi.Columns))
+	for _, col := range mi.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+// CheckFields - returns error if any of the given names is not a column
+func (mi *ModelInfo) CheckFields(names ...string) error {
+	for _, name := range names {
+		if mi.byName[name] == nil {
+			return errx.Errf(ErrUnknownField,
+				"'%s' is not a field of '%s'", name, mi.Name)
+		}
+	}
+	return nil
+}
+
+// KeyValue - value of the key field of given item, item must be a pointer to
+// the model struct
+func (mi *ModelInfo) KeyValue(item any) any {
+	return mi.field(item, mi.Key).Interface()
+}
+
+// IsNewItem - true if the key is generated by the database and the item
+// does not have a key yet
+func (mi *ModelInfo) IsNewItem(item any) bool {
+	return mi.Key.Auto && mi.field(item, mi.Key).IsZero()
+}
+
+// SetKey - sets the key field of item to given value, value is converted to
+// the field's type if possible
+func (mi *ModelInfo) SetKey(item any, key any) error {
+	fv := mi.field(item, mi.Key)
+	kv := reflect.ValueOf(key)
+	switch {
+	case !kv.IsValid():
+		fv.SetZero()
+	case kv.Type().AssignableTo(fv.Type()):
+		fv.Set(kv)
+	case kv.Type().ConvertibleTo(fv.Type()):
+		fv.Set(kv.Convert(fv.Type()))
+	default:
+		return errx.Errf(ErrInvalidModel,
+			"cannot set key of type '%s' to field of type '%s'",
+			kv.Type(), fv.Type())
+	}
+	return nil
+}
+
+// ParseKey - converts key given as string, say in a URL, to the type of the
+// key field. Types implementing encoding.TextUnmarshaler are supported
+func (mi *ModelInfo) ParseKey(str string) (any, error) {
+	ktype := mi.rtype.FieldByIndex(mi.Key.index).Type
+	ptr := reflect.New(ktype)
+	if tu, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+		if err := tu.UnmarshalText([]byte(str)); err != nil {
+			return nil, errx.Errf(err, "invalid key '%s'", str)
+		}
+		return ptr.Elem().Interface(), nil
+	}
+
+	val := ptr.Elem()
+	var err error
+	switch ktype.Kind() {
+	case reflect.String:
+		val.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		var num int64
+		num, err = strconv.ParseInt(str, 10, ktype.Bits())
+		val.SetInt(num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		var num uint64
+		num, err = strconv.ParseUint(str, 10, ktype.Bits())
+		val.SetUint(num)
+	default:
+		return str, nil
+	}
+	if err != nil {
+		return nil, errx.Errf(err, "invalid key '%s'", str)
+	}
+	return val.Interface(), nil
+}
+
+// KeyPtr - pointer to the key field of item, used to scan generated keys
+func (mi *ModelInfo) KeyPtr(item any) any {
+	return mi.field(item, mi.Key).Addr().Interface()
+}
+
+// Values - column names and values of the item. The key is left out if it
+// is generated by the database and withAutoKey is false
+func (mi *ModelInfo) Values(item any, withAutoKey bool) ([]string, []any) {
+	val := mi.structValue(item)
+	names := make([]string, 0, len(mi.Columns))
+	vals := make([]any, 0, len(mi.Columns))
+	for _, col := range mi.Columns {
+		if col.Auto && !withAutoKey {
+			continue
+		}
+		names = append(names, col.Name)
+		vals = append(vals, val.FieldByIndex(col.index).Interface())
+	}
+	return names, vals
+}
+
+func (mi *ModelInfo) field(item any, col *Column) reflect.Value {
+	return mi.structValue(item).FieldByIndex(col.index)
+}
+
+func (mi *ModelInfo) structValue(item any) reflect.Value {
+	val := reflect.ValueOf(item)
+	if val.Kind() != reflect.Ptr || val.Elem().Type() != mi.rtype {
+		panic(errx.Fmt("expected '*%s', got '%T'", mi.rtype, item))
+	}
+	return val.Elem()
+}
+
+func toSnakeCase(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for idx, r := range runes {
+		if unicode.IsUpper(r) {
+			if idx != 0 && (unicode.IsLower(runes[idx-1]) ||
+				(idx+1 < len(runes) && unicode.IsLower(runes[idx+1]))) {
+				sb.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
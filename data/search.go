@@ -0,0 +1,58 @@
+package data
+
+// SearchMode - how search filters on a field are matched by the database
+type SearchMode string
+
+const (
+	// SearchPattern - pattern match using SIMILAR TO, the default
+	SearchPattern SearchMode = ""
+
+	// SearchFullText - postgres full text search, the search text is parsed
+	// with websearch_to_tsquery, so it supports quoted phrases, 'or' and '-'
+	SearchFullText SearchMode = "fulltext"
+
+	// SearchTrigram - fuzzy match based on trigram similarity, needs the
+	// pg_trgm extension
+	SearchTrigram SearchMode = "trigram"
+)
+
+// SortRank - pseudo field for sorting by relevance of full text and trigram
+// searches in the filter, sort descending to get the best matches first.
+// It is ignored if the filter has no such searches
+const SortRank = "_rank"
+
+// HighlightsColumn - column with highlighted snippets of full text search
+// matches as a JSON object keyed by field. It is selected by the postgres
+// getter only if the result struct has a field for it, say:
+//
+//	Highlights data.M `db:"_highlights"`
+const HighlightsColumn = "_highlights"
+
+// TextSearch - options for searching a text field
+type TextSearch struct {
+	Mode SearchMode
+
+	// Config - text search configuration for full text search such as
+	// 'english', 'simple' is used if not given. For indexes to be used,
+	// expression indexes must use the same config:
+	//
+	//	CREATE INDEX ON item USING GIN (to_tsvector('english', body))
+	Config string
+
+	// Vector - tsvector column to match against instead of computing
+	// to_tsvector of the field's column
+	Vector string
+
+	// Threshold - minimum trigram similarity for a match. If it is 0, the
+	// '%' operator is used which honours pg_trgm.similarity_threshold and
+	// can use GIN/GiST trigram indexes
+	Threshold float64
+}
+
+// TextConfig - text search configuration to use
+func (ts *TextSearch) TextConfig() string {
+	if ts.Config == "" {
+		return "simple"
+	}
+	return ts.Config
+}
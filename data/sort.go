@@ -0,0 +1,119 @@
+package data
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidSort = errx.Declare(
+		"data.sort.invalid", http.StatusBadRequest,
+		"Invalid Sort", "sort specification is invalid")
+)
+
+// NullsOrder - position of null values in sorted results
+type NullsOrder string
+
+const (
+	// NullsDefault - store's default, nulls are last in ascending order for
+	// postgres and first for mongo
+	NullsDefault NullsOrder = ""
+	NullsFirst   NullsOrder = "first"
+	NullsLast    NullsOrder = "last"
+)
+
+// SortField - a field to sort by along with direction and position of nulls
+type SortField struct {
+	Field string     `json:"field" db:"field" bson:"field"`
+	Desc  bool       `json:"desc,omitempty" db:"desc" bson:"desc,omitempty"`
+	Nulls NullsOrder `json:"nulls,omitempty" db:"nulls" bson:"nulls,omitempty"`
+}
+
+// Validate - checks that the field is given and the nulls order is known
+func (sf *SortField) Validate() error {
+	if sf.Field == "" {
+		return errx.Errf(ErrInvalidSort, "sort field is empty")
+	}
+	switch sf.Nulls {
+	case NullsDefault, NullsFirst, NullsLast:
+		return nil
+	}
+	return errx.Errf(ErrInvalidSort,
+		"invalid nulls order '%s' for sort field '%s'", sf.Nulls, sf.Field)
+}
+
+// String - field prefixed with '-' if descending and suffixed with
+// ':nullsfirst' or ':nullslast' if nulls order is given, see ParseSorts
+func (sf SortField) String() string {
+	str := Qop(sf.Desc, "-", "") + sf.Field
+	if sf.Nulls != NullsDefault {
+		str += ":nulls" + string(sf.Nulls)
+	}
+	return str
+}
+
+// SortFields - fields to sort by. Sorts are used if given, otherwise Sort and
+// SortDescending are used. Returns nil if there is no sorting
+func (qp *CommonParams) SortFields() []SortField {
+	if len(qp.Sorts) != 0 {
+		return qp.Sorts
+	}
+	if qp.Sort != "" {
+		return []SortField{{Field: qp.Sort, Desc: qp.SortDescending}}
+	}
+	return nil
+}
+
+// ParseSorts - parses comma separated sort fields. Each field can be prefixed
+// with '-' for descending or '+' for ascending order and suffixed with
+// ':nullsfirst' or ':nullslast', e.g. 'status,-created:nullslast'
+func ParseSorts(str string) ([]SortField, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(str, ",")
+	sorts := make([]SortField, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		sf := SortField{}
+		if strings.HasPrefix(part, "-") {
+			sf.Desc = true
+			part = part[1:]
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+
+		field, nulls, hasNulls := strings.Cut(part, ":")
+		sf.Field = field
+		if hasNulls {
+			switch strings.ToLower(nulls) {
+			case "nullsfirst":
+				sf.Nulls = NullsFirst
+			case "nullslast":
+				sf.Nulls = NullsLast
+			default:
+				return nil, errx.Errf(ErrInvalidSort,
+					"invalid nulls order '%s' for sort field '%s'",
+					nulls, field)
+			}
+		}
+		if err := sf.Validate(); err != nil {
+			return nil, err
+		}
+		sorts = append(sorts, sf)
+	}
+	return sorts, nil
+}
+
+// FormatSorts - formats sort fields in the form accepted by ParseSorts
+func FormatSorts(sorts []SortField) string {
+	strs := make([]string, 0, len(sorts))
+	for _, sf := range sorts {
+		strs = append(strs, sf.String())
+	}
+	return strings.Join(strs, ",")
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/varunamachi/libx/errx"
@@ -93,7 +94,8 @@ func ToFlatMap(obj interface{}, tagName string) (out map[string]interface{}) {
 		VisitPrivate:     false,
 		VisitRootStruct:  false,
 		FieldNameRetriever: func(field *reflect.StructField) string {
-			jt := field.Tag.Get(tagName)
+			// Options like 'omitempty' are not part of the name
+			jt, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
 			if jt != "" {
 				return jt
 			}
@@ -0,0 +1,165 @@
+package errx
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Kind - classification of an error, an error can belong to multiple kinds
+type Kind uint8
+
+const (
+	KindNotFound Kind = 1 << iota
+	KindConflict
+	KindTimeout
+	KindRetryable
+)
+
+// Classifier - gives the kinds an error belongs to. Classifiers are called
+// for each error in the chain, so they need not unwrap the error
+type Classifier func(err error) Kind
+
+var classifiers struct {
+	mutex sync.RWMutex
+	list  []Classifier
+}
+
+// RegisterClassifier - adds a classifier for errors of a particular library,
+// for example data/mg registers one for mongo errors
+func RegisterClassifier(cls Classifier) {
+	classifiers.mutex.Lock()
+	defer classifiers.mutex.Unlock()
+	classifiers.list = append(classifiers.list, cls)
+}
+
+// Classify - gives the combined kinds of all the errors in the chain
+func Classify(err error) Kind {
+	classifiers.mutex.RLock()
+	defer classifiers.mutex.RUnlock()
+	return classify(err, 0)
+}
+
+func classify(err error, depth int) Kind {
+	if err == nil || depth > 100 {
+		return 0
+	}
+
+	kind := builtinKind(err)
+	for _, cls := range classifiers.list {
+		kind |= cls(err)
+	}
+
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		kind |= classify(x.Unwrap(), depth+1)
+	case interface{ Unwrap() []error }:
+		for _, e := range x.Unwrap() {
+			kind |= classify(e, depth+1)
+		}
+	}
+	return kind
+}
+
+// IsNotFound - true if the error indicates that a resource does not exist
+func IsNotFound(err error) bool {
+	return Classify(err)&KindNotFound != 0
+}
+
+// IsConflict - true if the error indicates that the operation conflicts with
+// existing state, such as a unique key violation
+func IsConflict(err error) bool {
+	return Classify(err)&KindConflict != 0
+}
+
+// IsTimeout - true if the error is due to an operation timing out
+func IsTimeout(err error) bool {
+	return Classify(err)&KindTimeout != 0
+}
+
+// IsRetryable - true if the operation that caused the error might succeed if
+// retried, such as on transient network errors or serialization failures
+func IsRetryable(err error) bool {
+	return Classify(err)&KindRetryable != 0
+}
+
+// StatusKind - kind for a HTTP status code
+func StatusKind(status int) Kind {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		return KindNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return KindConflict
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return KindTimeout | KindRetryable
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable:
+		return KindRetryable
+	}
+	return 0
+}
+
+// sqlStateErr - implemented by postgres driver errors, such as pq.Error
+type sqlStateErr interface {
+	SQLState() string
+}
+
+func builtinKind(err error) Kind {
+	switch err {
+	case sql.ErrNoRows, fs.ErrNotExist:
+		return KindNotFound
+	case fs.ErrExist:
+		return KindConflict
+	case context.DeadlineExceeded:
+		return KindTimeout
+	case syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED,
+		syscall.EPIPE:
+		return KindRetryable
+	}
+
+	if ec := codeOf(err); ec != nil {
+		return StatusKind(ec.Status)
+	}
+
+	switch x := err.(type) {
+	case sqlStateErr:
+		return sqlStateKind(x.SQLState())
+	case net.Error:
+		if x.Timeout() {
+			return KindTimeout | KindRetryable
+		}
+	}
+	return 0
+}
+
+// codeOf - registered code for the error itself, without unwrapping it
+func codeOf(err error) *ErrorCode {
+	if !isComparable(err) {
+		return nil
+	}
+	codes.mutex.RLock()
+	defer codes.mutex.RUnlock()
+	return codes.bySentinel[err]
+}
+
+func sqlStateKind(state string) Kind {
+	switch state {
+	case "23505", "23P01": // unique_violation, exclusion_violation
+		return KindConflict
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return KindConflict | KindRetryable
+	case "55P03", "53300": // lock_not_available, too_many_connections
+		return KindRetryable
+	case "57014": // query_canceled, raised on statement timeout
+		return KindTimeout
+	}
+	if strings.HasPrefix(state, "08") { // connection exceptions
+		return KindRetryable
+	}
+	return 0
+}
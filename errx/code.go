@@ -0,0 +1,118 @@
+package errx
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// ErrorCode - information about a well known error that is used when the
+// error is sent to a client. The code is also used by clients to map the
+// error back to the sentinel error
+type ErrorCode struct {
+	Code    string `json:"code"`
+	Status  int    `json:"status"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	err     error
+}
+
+// Err - the sentinel error associated with this code
+func (ec *ErrorCode) Err() error {
+	return ec.err
+}
+
+type registry struct {
+	mutex      sync.RWMutex
+	byCode     map[string]*ErrorCode
+	bySentinel map[error]*ErrorCode
+}
+
+var codes = &registry{
+	byCode:     map[string]*ErrorCode{},
+	bySentinel: map[error]*ErrorCode{},
+}
+
+// Declare - creates a sentinel error whose message is the code and registers
+// it with given HTTP status, title and default message. Meant to be used for
+// package level error variables:
+//
+//	var ErrNotFound = errx.Declare(
+//		"store.item.notFound", http.StatusNotFound, "Not Found", "item not found")
+func Declare(code string, status int, title, msg string) error {
+	err := errors.New(code)
+	Register(err, code, status, title, msg)
+	return err
+}
+
+// Register - registers an existing sentinel error against a code. Registering
+// the same code again replaces the earlier registration
+func Register(err error, code string, status int, title, msg string) {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	ec := &ErrorCode{
+		Code:    code,
+		Status:  status,
+		Title:   title,
+		Message: msg,
+		err:     err,
+	}
+
+	codes.mutex.Lock()
+	defer codes.mutex.Unlock()
+	if old, found := codes.byCode[code]; found {
+		delete(codes.bySentinel, old.err)
+	}
+	codes.byCode[code] = ec
+	codes.bySentinel[err] = ec
+}
+
+// LookupCode - gives the registered error code info for given code string,
+// nil if the code is not registered
+func LookupCode(code string) *ErrorCode {
+	codes.mutex.RLock()
+	defer codes.mutex.RUnlock()
+	return codes.byCode[code]
+}
+
+// Lookup - finds the first registered sentinel in the chain of given error,
+// outermost errors are checked first. Returns nil if there is none
+func Lookup(err error) *ErrorCode {
+	codes.mutex.RLock()
+	defer codes.mutex.RUnlock()
+	if len(codes.bySentinel) == 0 {
+		return nil
+	}
+	return lookup(err, 0)
+}
+
+func lookup(err error, depth int) *ErrorCode {
+	if err == nil || depth > 100 {
+		return nil
+	}
+	if isComparable(err) {
+		if ec, found := codes.bySentinel[err]; found {
+			return ec
+		}
+	}
+
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		return lookup(x.Unwrap(), depth+1)
+	case interface{ Unwrap() []error }:
+		for _, e := range x.Unwrap() {
+			if ec := lookup(e, depth+1); ec != nil {
+				return ec
+			}
+		}
+	}
+	return nil
+}
+
+// isComparable - errors with uncomparable dynamic types panic when used as
+// map keys
+func isComparable(err error) bool {
+	return reflect.TypeOf(err).Comparable()
+}
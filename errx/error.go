@@ -3,6 +3,8 @@ package errx
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"runtime"
 	"strings"
@@ -11,11 +13,27 @@ import (
 var MaxStackPrintDepth = 10
 
 type Error struct {
-	Err  error  `json:"err"`
-	Code string `json:"code"`
-	Msg  string `json:"msg"`
-	File string `json:"file"`
-	Line int    `json:"line"`
+	Err    error          `json:"err"`
+	Code   string         `json:"code"`
+	Msg    string         `json:"msg"`
+	File   string         `json:"file"`
+	Line   int            `json:"line"`
+	Fields map[string]any `json:"fields,omitempty"`
+	stack  []uintptr
+}
+
+// newError - creates error with file and line of the caller of the exported
+// constructor, the full stack is captured only if enabled
+func newError(inner error, code, msg string) *Error {
+	_, file, line, _ := runtime.Caller(2)
+	return &Error{
+		Err:   inner,
+		Code:  code,
+		Msg:   msg,
+		File:  file,
+		Line:  line,
+		stack: callers(3),
+	}
 }
 
 func (ex *Error) Error() string {
@@ -37,31 +55,17 @@ func (ex *Error) String() string {
 }
 
 func Fmt(msg string, args ...interface{}) error {
-	_, file, line, _ := runtime.Caller(1)
 	if len(args) > 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
-	return &Error{
-		Err:  nil,
-		Code: "",
-		Msg:  msg,
-		File: file,
-		Line: line,
-	}
+	return newError(nil, "", msg)
 }
 
 func Errf(inner error, msg string, args ...interface{}) error {
-	_, file, line, _ := runtime.Caller(1)
 	if len(args) > 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
-	return &Error{
-		Err:  inner,
-		Code: inner.Error(),
-		Msg:  msg,
-		File: file,
-		Line: line,
-	}
+	return newError(inner, inner.Error(), msg)
 }
 
 func Wrap(inner error) error {
@@ -81,56 +85,26 @@ func Wrap(inner error) error {
 	}
 
 	// errName := reflect.TypeOf(inner).String()
-	_, file, line, _ := runtime.Caller(1)
-
-	return &Error{
-		Err:  inner,
-		Code: code,
-		Msg:  msg,
-		File: file,
-		Line: line,
-	}
+	return newError(inner, code, msg)
 }
 
 func Todo(emsg string) error {
-	_, file, line, _ := runtime.Caller(1)
-	return &Error{
-		Err:  nil,
-		Code: "TODO",
-		Msg:  "TODO: " + emsg,
-		File: file,
-		Line: line,
-	}
+	return newError(nil, "TODO", "TODO: "+emsg)
 }
 
 func Errfx(inner error, code, msg string, args ...interface{}) error {
-	_, file, line, _ := runtime.Caller(1)
 	if len(args) > 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
-	return &Error{
-		Err:  inner,
-		Code: code,
-		Msg:  msg,
-		File: file,
-		Line: line,
-	}
+	return newError(inner, code, msg)
 }
 
 func New(code, msg string) error {
-	_, file, line, _ := runtime.Caller(1)
-	return &Error{
-		Err:  errors.New(code),
-		Code: code,
-		Msg:  msg,
-		File: file,
-		Line: line,
-	}
+	return newError(errors.New(code), code, msg)
 }
 
 func Str(err error) string {
 	if err != nil {
-		fmt.Println(err, err != nil)
 		ex, ok := err.(*Error)
 		if !ok {
 			return err.Error()
@@ -140,16 +114,35 @@ func Str(err error) string {
 	return "N/A"
 }
 
+// PrintSomeStack - prints the chain of errors to stderr
 func PrintSomeStack(err error) {
-	fmt.Println()
-	stackPrinter(err, true, 0)
-	fmt.Println()
+	FprintSomeStack(os.Stderr, err)
+}
+
+// FprintSomeStack - prints the chain of errors to given writer. If the stack
+// was captured for the outermost error it is printed as well
+func FprintSomeStack(w io.Writer, err error) {
+	fmt.Fprintln(w)
+	stackPrinter(w, err, true, 0)
+	if ex, ok := err.(*Error); ok && len(ex.stack) != 0 {
+		fmt.Fprintln(w, "stack:")
+		for _, frame := range ex.StackTrace() {
+			fmt.Fprintln(w, "   ", frame)
+		}
+	}
+	fmt.Fprintln(w)
 }
 
-func stackPrinter(err error, first bool, idx int) {
+func stackPrinter(w io.Writer, err error, first bool, idx int) {
 	if err == nil {
 		return
 	}
+	if multi, ok := err.(*Multi); ok {
+		for _, inner := range multi.errs {
+			stackPrinter(w, inner, first, idx)
+		}
+		return
+	}
 	ex, ok := err.(*Error)
 	if ok {
 
@@ -161,15 +154,15 @@ func stackPrinter(err error, first bool, idx int) {
 			indent += "\u2BC8 "
 		}
 
-		fmt.Printf("%s%s%s:%d \u2B9E %s\n",
+		fmt.Fprintf(w, "%s%s%s:%d \u2B9E %s\n",
 			sym, indent, ex.File, ex.Line, ex.Msg)
 		if idx < MaxStackPrintDepth {
-			stackPrinter(ex.Err, false, idx+1)
+			stackPrinter(w, ex.Err, false, idx+1)
 		}
 		return
 	}
 	indent := strings.Repeat("\u2500", idx)
-	fmt.Printf("\u2514%s\u2BC8 %v\n", indent, err.Error())
+	fmt.Fprintf(w, "\u2514%s\u2BC8 %v\n", indent, err.Error())
 }
 
 func StackArray(err error) []string {
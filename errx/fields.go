@@ -0,0 +1,57 @@
+package errx
+
+import (
+	"fmt"
+	"maps"
+)
+
+// With - attaches key value pairs to the error as context, which are logged
+// along with it. Given error is not modified, a new error is returned
+//
+//	return errx.With(err, "userId", id, "op", "update")
+func With(err error, kvs ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	var out *Error
+	if ex, ok := err.(*Error); ok && ex != nil {
+		clone := *ex
+		clone.Fields = maps.Clone(ex.Fields)
+		out = &clone
+	} else {
+		out = newError(err, err.Error(), err.Error())
+	}
+	if out.Fields == nil {
+		out.Fields = make(map[string]any, len(kvs)/2)
+	}
+
+	for idx := 0; idx < len(kvs); idx += 2 {
+		key := fmt.Sprint(kvs[idx])
+		if idx+1 == len(kvs) {
+			out.Fields[key] = nil
+			break
+		}
+		out.Fields[key] = kvs[idx+1]
+	}
+	return out
+}
+
+// Fields - gives the context fields attached to all the errors in the chain,
+// values from outer errors take precedence
+func Fields(err error) map[string]any {
+	out := map[string]any{}
+	for depth := 0; err != nil && depth < MaxStackPrintDepth; depth++ {
+		ex, ok := err.(*Error)
+		if !ok || ex == nil {
+			break
+		}
+		for key, val := range ex.Fields {
+			if _, found := out[key]; !found {
+				out[key] = val
+			}
+		}
+		err = ex.Err
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package errx
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+)
+
+// MarshalZerologObject - logs the error with its code, location, context
+// fields, captured stack and the chain of causes, so that
+// log.Error().Err(err) gives structured output
+func (ex *Error) MarshalZerologObject(evt *zerolog.Event) {
+	evt.Str("error", ex.Error())
+	marshalOne(evt, ex)
+	if fields := Fields(ex); len(fields) != 0 {
+		evt.Dict("fields", zerolog.Dict().Fields(fields))
+	}
+	if stack := ex.StackTrace(); len(stack) != 0 {
+		evt.Strs("stack", stack)
+	}
+
+	causes := zerolog.Arr()
+	count := 0
+	for err := ex.Err; err != nil && count < MaxStackPrintDepth; count++ {
+		inner, ok := err.(*Error)
+		if !ok || inner == nil {
+			causes.Str(err.Error())
+			err = errors.Unwrap(err)
+			continue
+		}
+		causes.Object(cause{inner})
+		err = inner.Err
+	}
+	if count != 0 {
+		evt.Array("causes", causes)
+	}
+}
+
+type cause struct {
+	ex *Error
+}
+
+func (c cause) MarshalZerologObject(evt *zerolog.Event) {
+	marshalOne(evt, c.ex)
+	if len(c.ex.Fields) != 0 {
+		evt.Dict("fields", zerolog.Dict().Fields(c.ex.Fields))
+	}
+}
+
+func marshalOne(evt *zerolog.Event, ex *Error) {
+	if ex.Code != "" {
+		evt.Str("code", ex.Code)
+	}
+	evt.Str("msg", ex.Msg).
+		Str("file", ex.File).
+		Int("line", ex.Line)
+}
@@ -0,0 +1,95 @@
+package errx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Multi - aggregate of errors, behaves like the error returned by errors.Join.
+// Entries are kept as they are, so errx.Error entries retain their file and
+// line. The zero value is ready to use
+type Multi struct {
+	errs []error
+}
+
+// Join - creates an aggregate error from non-nil errors among given ones, nil
+// is returned if there are none. Nested Multi errors are flattened
+func Join(errs ...error) error {
+	m := &Multi{}
+	for _, err := range errs {
+		m.Add(err)
+	}
+	return m.Err()
+}
+
+// Add - adds the error to the aggregate, nil errors are ignored
+func (m *Multi) Add(err error) *Multi {
+	if err == nil {
+		return m
+	}
+	if other, ok := err.(*Multi); ok {
+		m.errs = append(m.errs, other.errs...)
+		return m
+	}
+	m.errs = append(m.errs, err)
+	return m
+}
+
+// Addf - wraps the error with a message and location of the caller before
+// adding it, nil errors are ignored
+func (m *Multi) Addf(err error, msg string, args ...any) *Multi {
+	if err == nil {
+		return m
+	}
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	m.errs = append(m.errs, newError(err, err.Error(), msg))
+	return m
+}
+
+// Len - number of errors in the aggregate
+func (m *Multi) Len() int {
+	return len(m.errs)
+}
+
+// Errors - errors in the aggregate
+func (m *Multi) Errors() []error {
+	return m.errs
+}
+
+// Err - gives the aggregate as an error, nil if it is empty
+func (m *Multi) Err() error {
+	if m == nil || len(m.errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *Multi) Error() string {
+	strs := make([]string, 0, len(m.errs))
+	for _, err := range m.errs {
+		strs = append(strs, Str(err))
+	}
+	return strings.Join(strs, "\n")
+}
+
+func (m *Multi) Unwrap() []error {
+	return m.errs
+}
+
+// MarshalZerologObject - logs each of the aggregated errors
+func (m *Multi) MarshalZerologObject(evt *zerolog.Event) {
+	evt.Str("error", m.Error())
+	arr := zerolog.Arr()
+	for _, err := range m.errs {
+		if ex, ok := err.(*Error); ok && ex != nil {
+			arr.Object(ex)
+			continue
+		}
+		arr.Str(err.Error())
+	}
+	evt.Array("errors", arr)
+}
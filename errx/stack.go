@@ -0,0 +1,59 @@
+package errx
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// EnvCaptureStack - full stack is captured when errors are created if
+	// this is set to true. Building with 'errxstack' tag enables it as well
+	EnvCaptureStack = "VLIBX_ERR_STACK"
+)
+
+// MaxStackDepth - maximum number of frames captured for an error
+var MaxStackDepth = 32
+
+var stackEnabled atomic.Bool
+
+func init() {
+	stackEnabled.Store(stackBuildTag ||
+		strings.EqualFold(os.Getenv(EnvCaptureStack), "true"))
+}
+
+// CaptureStack - enables or disables capturing of full stack traces for
+// errors created after the call
+func CaptureStack(enable bool) {
+	stackEnabled.Store(enable)
+}
+
+func callers(skip int) []uintptr {
+	if !stackEnabled.Load() {
+		return nil
+	}
+	pcs := make([]uintptr, MaxStackDepth)
+	n := runtime.Callers(skip+1, pcs)
+	return pcs[:n]
+}
+
+// StackTrace - stack captured when the error was created, one entry per frame
+// in 'function file:line' form. Empty if stack capture was not enabled
+func (ex *Error) StackTrace() []string {
+	if len(ex.stack) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(ex.stack))
+	frames := runtime.CallersFrames(ex.stack)
+	for {
+		frame, more := frames.Next()
+		out = append(out, fmt.Sprintf(
+			"%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,5 @@
+//go:build errxstack
+
+package errx
+
+const stackBuildTag = true
@@ -0,0 +1,412 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/varunamachi/libx/errx"
+	"github.com/varunamachi/libx/str"
+)
+
+var (
+	ErrBindTarget = errors.New("httpx.bind.invalidTarget")
+)
+
+// Sources for a bound field
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InBody   = "body"
+)
+
+// FieldError - describes why a single request field is invalid
+type FieldError struct {
+	Field  string `json:"field"`
+	In     string `json:"in,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError - list of invalid fields in a request
+type ValidationError struct {
+	Fields []*FieldError `json:"fields"`
+}
+
+func (ve *ValidationError) Error() string {
+	return "invalid fields: " + ve.names()
+}
+
+func (ve *ValidationError) names() string {
+	names := make([]string, 0, len(ve.Fields))
+	for _, fe := range ve.Fields {
+		names = append(names, fe.Field)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (ve *ValidationError) add(field, in, reason string) {
+	ve.Fields = append(ve.Fields, &FieldError{
+		Field:  field,
+		In:     in,
+		Reason: reason,
+	})
+}
+
+// newValidationHTTPError - bad request error that carries per field details,
+// the details are sent to the client by the error handler
+func newValidationHTTPError(ve *ValidationError) *echo.HTTPError {
+	return &echo.HTTPError{
+		Code:     http.StatusBadRequest,
+		Message:  "http parameter error: " + ve.names(),
+		Internal: ve,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind - creates an instance of T and fills it from the request. The JSON body
+// is decoded first, then fields tagged with `path`, `query` or `header` are
+// set from the respective parts of the request. The `default` tag gives the
+// value used when a param is absent. The `validate` tag holds comma separated
+// rules: required, min=N, max=N, len=N and oneof=a b c. For strings and
+// slices min, max and len apply to length. Body fields are present if their
+// keys are in the body with non-null values. All the invalid fields are
+// reported together in a 400 error. A field that is both required and has a
+// default is a bug in T, ErrBindTarget is returned for it
+//
+//	type ListReq struct {
+//		Id   int64  `path:"id" validate:"min=1"`
+//		Page int    `query:"page" default:"0" validate:"min=0,max=100"`
+//		Name string `json:"name" validate:"required"`
+//	}
+func Bind[T any](etx echo.Context) (*T, error) {
+	out := new(T)
+	val := reflect.ValueOf(out).Elem()
+	if val.Kind() != reflect.Struct {
+		return nil, errx.Errf(ErrBindTarget, "'%T' is not a struct", *out)
+	}
+
+	ve := &ValidationError{}
+	keys, err := decodeBody(etx.Request(), out)
+	if err != nil {
+		ve.add("body", InBody, err.Error())
+	}
+
+	if err := bindStruct(etx, val, keys, ve); err != nil {
+		return nil, err
+	}
+	if len(ve.Fields) != 0 {
+		return nil, newValidationHTTPError(ve)
+	}
+	return out, nil
+}
+
+// decodeBody - decodes JSON body of the request into out, gives the keys in
+// the body that have non-null values. Requests without a JSON body are not
+// decoded
+func decodeBody(req *http.Request, out any) (map[string]bool, error) {
+	if req.Body == nil || req.ContentLength == 0 ||
+		!strings.HasPrefix(req.Header.Get(echo.HeaderContentType),
+			echo.MIMEApplicationJSON) {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(fields))
+	for key, raw := range fields {
+		if string(raw) != "null" {
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+func bindStruct(
+	etx echo.Context,
+	val reflect.Value,
+	keys map[string]bool,
+	ve *ValidationError) error {
+	tp := val.Type()
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		fv := val.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindStruct(etx, fv, keys, ve); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rules := sf.Tag.Get("validate")
+		if _, ok := sf.Tag.Lookup("default"); ok && hasRule(rules, "required") {
+			return errx.Errf(ErrBindTarget,
+				"field '%s' of '%s' is required and also has a default",
+				sf.Name, tp)
+		}
+
+		name, in, raw, present := paramValue(etx, &sf)
+		if in == "" {
+			// Body field, only validation applies
+			name = jsonName(&sf)
+			in = InBody
+			present = hasKey(keys, name)
+		} else {
+			if !present {
+				// Default is validated like a value given in the request
+				if def, ok := sf.Tag.Lookup("default"); ok {
+					raw, present = []string{def}, true
+				}
+			}
+			if len(raw) != 0 {
+				if err := setValue(fv, raw); err != nil {
+					ve.add(name, in, err.Error())
+					continue
+				}
+			}
+		}
+
+		if rules != "" {
+			if reason := validate(fv, present, rules); reason != "" {
+				ve.add(name, in, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// hasKey - tells if the body has the key, keys are matched case-insensitively
+// like encoding/json does when decoding
+func hasKey(keys map[string]bool, name string) bool {
+	if keys[name] {
+		return true
+	}
+	for key := range keys {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRule - tells if the comma separated rules have the named rule
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		rule, _, _ = strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func paramValue(
+	etx echo.Context,
+	sf *reflect.StructField) (name, in string, raw []string, present bool) {
+	if name = sf.Tag.Get(InPath); name != "" {
+		val := etx.Param(name)
+		return name, InPath, nonEmpty(val), val != ""
+	}
+	if name = sf.Tag.Get(InQuery); name != "" {
+		vals, found := etx.QueryParams()[name]
+		return name, InQuery, vals, found
+	}
+	if name = sf.Tag.Get(InHeader); name != "" {
+		vals := etx.Request().Header.Values(name)
+		return name, InHeader, vals, len(vals) != 0
+	}
+	return "", "", nil, false
+}
+
+func nonEmpty(val string) []string {
+	if val == "" {
+		return nil
+	}
+	return []string{val}
+}
+
+func jsonName(sf *reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func setValue(fv reflect.Value, raw []string) error {
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), raw); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		// Both repeated params and comma separated values are accepted
+		parts := make([]string, 0, len(raw))
+		for _, r := range raw {
+			parts = append(parts, strings.Split(r, ",")...)
+		}
+		slc := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for idx, part := range parts {
+			if err := setScalar(slc.Index(idx), part); err != nil {
+				return err
+			}
+		}
+		fv.Set(slc)
+		return nil
+	}
+	return setScalar(fv, raw[0])
+}
+
+func setScalar(fv reflect.Value, raw string) error {
+	switch {
+	case fv.Type() == timeType:
+		tm, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errors.New("expected RFC3339 time")
+		}
+		fv.Set(reflect.ValueOf(tm))
+		return nil
+	case fv.Type() == durationType:
+		dur, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("expected a duration like 10s")
+		}
+		fv.SetInt(int64(dur))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		if str.EqFold(raw, "true", "on", "1") {
+			fv.SetBool(true)
+		} else if str.EqFold(raw, "false", "off", "0") {
+			fv.SetBool(false)
+		} else {
+			return errors.New("expected a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		val, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		fv.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		val, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+		if err != nil {
+			return errors.New("expected a non-negative integer")
+		}
+		fv.SetUint(val)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(raw, fv.Type().Bits())
+		if err != nil {
+			return errors.New("expected a number")
+		}
+		fv.SetFloat(val)
+	default:
+		return fmt.Errorf("unsupported field type '%s'", fv.Type())
+	}
+	return nil
+}
+
+// validate - checks the value against given rules and returns the reason if
+// it is invalid, empty string is returned for valid values. Rules other than
+// required do not apply to absent values
+func validate(fv reflect.Value, present bool, rules string) string {
+	if !present || (fv.Kind() == reflect.Ptr && fv.IsNil()) {
+		if hasRule(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+	if fv.Kind() == reflect.Ptr {
+		fv = fv.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Sprintf("invalid rule '%s'", rule)
+			}
+			size, isLen := measure(fv)
+			what := "value"
+			if isLen {
+				what = "length"
+			}
+			switch {
+			case name == "min" && size < limit:
+				return fmt.Sprintf("%s must be at least %v", what, arg)
+			case name == "max" && size > limit:
+				return fmt.Sprintf("%s must be at most %v", what, arg)
+			case name == "len" && size != limit:
+				return fmt.Sprintf("%s must be exactly %v", what, arg)
+			}
+		case "oneof":
+			opts := strings.Fields(arg)
+			if !slices.Contains(opts, fmt.Sprint(fv.Interface())) {
+				return "must be one of: " + strings.Join(opts, ", ")
+			}
+		default:
+			return fmt.Sprintf("unknown validation rule '%s'", name)
+		}
+	}
+	return ""
+}
+
+// measure - gives the number that min/max/len compare against, length for
+// strings and containers, value for numbers
+func measure(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	}
+	return 0, false
+}
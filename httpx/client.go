@@ -75,28 +75,43 @@ func newApiResult(req *http.Request, resp *http.Response) *ApiResult {
 		return res
 	}
 	if resp.Body == nil {
-		res.err = errx.Errf(err, "%s - %s - No body", resp.Status, target)
+		res.err = errx.Errf(&clientError{err: err, status: resp.StatusCode},
+			"%s - %s - No body", resp.Status, target)
 		return res
 	}
 
 	defer resp.Body.Close()
-	msg := ""
 
-	bbytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		msg = "failed to get error message: " + err.Error()
-	} else {
-		errMap := map[string]string{}
-		reader := bytes.NewReader(bbytes)
-		if err := json.NewDecoder(reader).Decode(&errMap); err != nil {
-			msg = "unknown error: " + string(bbytes)
-		} else {
-			msg = errMap["msg"]
-			msg = data.Qop(msg == "", string(bbytes), msg)
-		}
+	bbytes, rerr := io.ReadAll(resp.Body)
+	if rerr != nil {
+		res.err = errx.Errf(&clientError{err: err, status: resp.StatusCode},
+			"%s - failed to get error message: %s", resp.Status, rerr.Error())
+		return res
+	}
+
+	// Problem details are sent by servers using this package, older servers
+	// send a JSON object with 'msg' and 'errorCode'. Status is taken from the
+	// response since older servers send status text in it
+	body := struct {
+		Problem
+		Status    any    `json:"status"`
+		Msg       string `json:"msg"`
+		ErrorCode string `json:"errorCode"`
+	}{}
+	if json.Unmarshal(bbytes, &body) != nil {
+		res.err = errx.Errf(&clientError{err: err, status: resp.StatusCode},
+			"%s - unknown error: %s", resp.Status, string(bbytes))
+		return res
+	}
+	prob := body.Problem
+	if prob.Code == "" {
+		prob.Code = body.ErrorCode
+	}
+	if prob.Detail == "" {
+		prob.Detail = data.Qop(body.Msg == "", string(bbytes), body.Msg)
 	}
-	// err =
-	res.err = errx.Errf(ErrClientError, "%s - %s", resp.Status, msg)
+	prob.Status = resp.StatusCode
+	res.err = prob.toError(err)
 	return res
 
 	// First we check if this is in the form of echo.HttpError, if so we try to
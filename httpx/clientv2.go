@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"net/url"
 	"path"
@@ -26,7 +25,7 @@ type RequestBuilder struct {
 	queryParams map[string]string
 	path        string
 	withAuth    bool
-	errs        []error
+	errs        errx.Multi
 
 	// timeout     time.Duration
 	// TODO - now only json is supported, when others are to be supported, we
@@ -41,7 +40,6 @@ func newRequestBuilder(client *Client) *RequestBuilder {
 		queryParams: map[string]string{},
 		path:        "",
 		withAuth:    true,
-		errs:        make([]error, 0, 10),
 	}
 }
 
@@ -82,7 +80,7 @@ func (rb *RequestBuilder) HdrBool(name string, value bool) *RequestBuilder {
 func (rb *RequestBuilder) HdrJson(name string, value any) *RequestBuilder {
 	j, err := encodeJsonUrl(value)
 	if err != nil {
-		rb.errs = append(rb.errs, err)
+		rb.errs.Add(err)
 		return rb
 	}
 
@@ -90,13 +88,30 @@ func (rb *RequestBuilder) HdrJson(name string, value any) *RequestBuilder {
 	return rb
 }
 
+// CmnParam - sets common params as query params. Multiple sort fields are
+// encoded in the 'sort' param as given by data.FormatSorts
 func (rb *RequestBuilder) CmnParam(cp *data.CommonParams) *RequestBuilder {
-	return rb.
+	rb.
 		QInt("page", cp.Page).
 		QInt("pageSize", cp.PageSize).
-		QStr("sort", cp.Sort).
-		QBool("sortDesc", cp.SortDescending).
 		Filter(cp.Filter)
+	if len(cp.Sorts) != 0 {
+		rb.QStr("sort", data.FormatSorts(cp.Sorts))
+	} else {
+		rb.
+			QStr("sort", cp.Sort).
+			QBool("sortDesc", cp.SortDescending)
+	}
+	if cp.Cursor != "" {
+		rb.QStr("cursor", cp.Cursor)
+	}
+	return rb
+}
+
+// FilterQuery - sets the filter as a query in the filter query language,
+// see data.ParseFilter
+func (rb *RequestBuilder) FilterQuery(query string) *RequestBuilder {
+	return rb.QStr("q", query)
 }
 
 func (rb *RequestBuilder) Filter(f *data.Filter) *RequestBuilder {
@@ -126,7 +141,7 @@ func (rb *RequestBuilder) QBool(name string, value bool) *RequestBuilder {
 func (rb *RequestBuilder) QJson(name string, value any) *RequestBuilder {
 	j, err := encodeJsonUrl(value)
 	if err != nil {
-		rb.errs = append(rb.errs, err)
+		rb.errs.Add(err)
 		return rb
 	}
 
@@ -151,8 +166,8 @@ func (rb *RequestBuilder) Path(params ...any) *RequestBuilder {
 		case bool:
 			_, err = sb.WriteString(strconv.FormatBool(p))
 		default:
-			rb.errs = append(rb.errs,
-				fmt.Errorf("invalid param type for path: '%T'", p))
+			rb.errs.Add(errx.Errf(ErrHttpRequestBuildFailed,
+				"invalid param type for path: '%T'", p))
 			return rb
 		}
 
@@ -161,7 +176,7 @@ func (rb *RequestBuilder) Path(params ...any) *RequestBuilder {
 		}
 
 		if err != nil {
-			rb.errs = append(rb.errs, err)
+			rb.errs.Add(err)
 			return rb
 		}
 	}
@@ -182,11 +197,11 @@ func (rb *RequestBuilder) WithTimeout(duration time.Duration) *RequestBuilder {
 
 func (rb *RequestBuilder) Exec(
 	gtx context.Context, method string, body any) *ApiResult {
-	if len(rb.errs) != 0 {
+	if rb.errs.Len() != 0 {
 		return &ApiResult{
-			err:            ErrHttpRequestBuildFailed,
+			err:            errx.Join(ErrHttpRequestBuildFailed, &rb.errs),
 			target:         method + " " + rb.path,
-			reqBuildErrors: rb.errs,
+			reqBuildErrors: rb.errs.Errors(),
 		}
 	}
 
@@ -2,8 +2,9 @@ package httpx
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
@@ -74,6 +75,22 @@ func retrieveUserId(ctx echo.Context) (int64, string, string, error) {
 func getAuthzMiddleware(ep *Endpoint, server *Server) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(etx echo.Context) error {
+			// Users identified by a verified client certificate do not need
+			// a JWT
+			if cert := verifiedClientCert(etx); cert != nil &&
+				server.tlsOpts != nil && server.tlsOpts.CertMapper != nil {
+				user, err := server.tlsOpts.CertMapper.UserFromCert(
+					etx.Request().Context(), cert)
+				if err != nil {
+					return &echo.HTTPError{
+						Code:     http.StatusUnauthorized,
+						Message:  "invalid client certificate",
+						Internal: err,
+					}
+				}
+				return authorize(etx, ep, user, user.Id(), user.Username(), next)
+			}
+
 			id, userName, userType, err := retrieveUserId(etx)
 			if err != nil {
 				return &echo.HTTPError{
@@ -111,30 +128,52 @@ func getAuthzMiddleware(ep *Endpoint, server *Server) echo.MiddlewareFunc {
 			if err != nil {
 				return errx.Wrap(err)
 			}
+			return authorize(etx, ep, user, id, userName, next)
+		}
+	}
+}
 
-			hasAccess := auth.HasPerms(user, ep.Permissions...) &&
-				auth.HasRole(user, ep.Role)
-			if !hasAccess {
-				return &echo.HTTPError{
-					// Code:    http.StatusUnauthorized,
-					Code:    http.StatusForbidden,
-					Message: "permission to access resource is denied",
-				}
-			}
+func authorize(
+	etx echo.Context,
+	ep *Endpoint,
+	user auth.User,
+	id int64,
+	userName string,
+	next echo.HandlerFunc) error {
 
-			etx.Set("endpoint", ep)
-			etx.Set("user", user)
-			etx.Set("username", userName)
-			etx.Set("id", id)
+	hasAccess := auth.HasPerms(user, ep.Permissions...) &&
+		auth.HasRole(user, ep.Role)
+	if !hasAccess {
+		return &echo.HTTPError{
+			// Code:    http.StatusUnauthorized,
+			Code:    http.StatusForbidden,
+			Message: "permission to access resource is denied",
+		}
+	}
 
-			// Make user information part of the request context
-			gtx := context.WithValue(etx.Request().Context(), UserKey, user)
-			req := etx.Request().WithContext(gtx)
-			etx.SetRequest(req)
+	etx.Set("endpoint", ep)
+	etx.Set("user", user)
+	etx.Set("username", userName)
+	etx.Set("id", id)
 
-			return next(etx)
-		}
+	// Make user information part of the request context
+	gtx := context.WithValue(etx.Request().Context(), UserKey, user)
+	req := etx.Request().WithContext(gtx)
+	etx.SetRequest(req)
+
+	return next(etx)
+}
+
+// verifiedClientCert - gives the leaf client certificate if the client
+// presented one that was verified during TLS handshake
+func verifiedClientCert(etx echo.Context) *x509.Certificate {
+	state := etx.Request().TLS
+	if state == nil ||
+		len(state.VerifiedChains) == 0 ||
+		len(state.VerifiedChains[0]) == 0 {
+		return nil
 	}
+	return state.VerifiedChains[0][0]
 }
 
 // func getAccessMiddleware() echo.MiddlewareFunc {
@@ -235,64 +274,27 @@ func accessMiddleware(printErrors bool) echo.MiddlewareFunc {
 }
 
 func errorHandlerFunc(err error, etx echo.Context) {
-	asJson := func(status int, code, msg string) {
-		hm := map[string]string{
-			"status":    http.StatusText(status),
-			"errorCode": code,
-			"msg":       msg,
-		}
-		if err := etx.JSON(status, hm); err != nil {
-			log.Error().Err(err).Msg("failed to send error to client")
-		}
-	}
-
-	printIfInternal := func(status int, err error) bool {
-		irr, ok := err.(*errx.Error)
-		if !ok {
-			return false
-		}
-		log.Error().
-			Int("statusCode", status).
-			Str("file", irr.File).
-			Int("line", irr.Line).
-			Str("user", GetUsername(etx)).
-			Str("method", etx.Request().Method).
-			Str("path", etx.Request().URL.Path).
-			Msg(irr.Msg)
-		errx.PrintSomeStack(irr)
-		asJson(status, irr.Code, irr.Msg)
-		return true
-	}
-
 	if err == nil {
 		return
 	}
 
-	if printIfInternal(http.StatusInternalServerError, err) {
-		return
-	}
+	prob := newProblem(etx, err)
+	evt := log.Error().
+		Int("statusCode", prob.Status).
+		Str("code", prob.Code).
+		Str("traceId", prob.TraceId).
+		Str("user", GetUsername(etx)).
+		Str("method", etx.Request().Method).
+		Str("path", etx.Request().URL.Path)
 
-	httpErr, ok := err.(*echo.HTTPError)
-	if ok && printIfInternal(httpErr.Code, httpErr.Internal) {
-		return
-	}
-	if ok {
-		msg := StrMsg(httpErr)
-		log.Error().
-			Int("statusCode", httpErr.Code).
-			Str("user", GetUsername(etx)).
-			Str("method", etx.Request().Method).
-			Str("path", etx.Request().URL.Path).
-			Msg(msg)
-		asJson(httpErr.Code, strconv.Itoa(httpErr.Code), msg)
-		return
+	var irr *errx.Error
+	if errors.As(err, &irr) {
+		evt.Err(irr).Msg(irr.Msg)
+	} else {
+		evt.Msg(prob.Detail)
 	}
 
-	log.Error().
-		Int("statusCode", http.StatusInternalServerError).
-		Str("user", GetUsername(etx)).
-		Str("method", etx.Request().Method).
-		Str("path", etx.Request().URL.Path).
-		Msg(err.Error())
-	asJson(http.StatusInternalServerError, "500", err.Error())
+	if err := sendProblem(etx, prob); err != nil {
+		log.Error().Err(err).Msg("failed to send error to client")
+	}
 }
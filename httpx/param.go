@@ -1,14 +1,16 @@
 package httpx
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
@@ -18,21 +20,33 @@ import (
 )
 
 var (
-	ErrHttpParam = errors.New("error.http.param")
+	ErrHttpParam = errx.Declare("error.http.param", http.StatusBadRequest,
+		"Invalid Parameter", "one or more request parameters are invalid")
 )
 
 type ParamGetter struct {
 	etx  echo.Context
-	errs map[string]error
+	errs errx.Multi
 }
 
 func NewParamGetter(etx echo.Context) *ParamGetter {
 	return &ParamGetter{
-		etx:  etx,
-		errs: make(map[string]error),
+		etx: etx,
 	}
 }
 
+// fail - records the error for the named param, the name is attached to the
+// error as 'param' field
+func (pm *ParamGetter) fail(name string, err error) {
+	pm.errs.Add(errx.With(err, "param", name))
+}
+
+// paramOf - name of the param the error recorded by fail is for
+func paramOf(err error) string {
+	name, _ := errx.Fields(err)["param"].(string)
+	return name
+}
+
 func (pm *ParamGetter) Str(name string) string {
 	return pm.etx.Param(name)
 }
@@ -41,7 +55,7 @@ func (pm *ParamGetter) Int(name string) int {
 	param := pm.etx.Param(name)
 	val, err := strconv.Atoi(param)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -50,7 +64,7 @@ func (pm *ParamGetter) Int64(name string) int64 {
 	param := pm.etx.Param(name)
 	val, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -59,7 +73,7 @@ func (pm *ParamGetter) UInt(name string) uint {
 	param := pm.etx.Param(name)
 	val, err := strconv.ParseUint(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return uint(val)
 }
@@ -68,7 +82,7 @@ func (pm *ParamGetter) UInt64(name string) uint64 {
 	param := pm.etx.Param(name)
 	val, err := strconv.ParseUint(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -77,7 +91,7 @@ func (pm *ParamGetter) Float64(name string) float64 {
 	param := pm.etx.Param(name)
 	val, err := strconv.ParseFloat(param, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -89,13 +103,13 @@ func (pm *ParamGetter) Bool(name string) bool {
 	} else if str.EqFold(param, "false", "off") {
 		return false
 	}
-	pm.errs[name] = errors.New("invalid string for bool param")
+	pm.fail(name, errors.New("invalid string for bool param"))
 	return false
 }
 
 func (pm *ParamGetter) QueryStr(name string) string {
 	if !pm.etx.QueryParams().Has(name) {
-		pm.errs[name] = errors.New("query param not found")
+		pm.fail(name, errors.New("query param not found"))
 	}
 	return pm.etx.QueryParam(name)
 }
@@ -104,7 +118,7 @@ func (pm *ParamGetter) QueryInt(name string) int {
 	param := pm.etx.QueryParam(name)
 	val, err := strconv.Atoi(param)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -113,7 +127,7 @@ func (pm *ParamGetter) QueryInt64(name string) int64 {
 	param := pm.etx.QueryParam(name)
 	val, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -122,7 +136,7 @@ func (pm *ParamGetter) QueryUInt(name string) uint {
 	param := pm.etx.QueryParam(name)
 	val, err := strconv.ParseUint(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return uint(val)
 }
@@ -131,7 +145,7 @@ func (pm *ParamGetter) QueryUInt64Param(name string) uint64 {
 	param := pm.etx.QueryParam(name)
 	val, err := strconv.ParseUint(param, 10, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -140,7 +154,7 @@ func (pm *ParamGetter) QueryFloat64(name string) float64 {
 	param := pm.etx.QueryParam(name)
 	val, err := strconv.ParseFloat(param, 64)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 	}
 	return val
 }
@@ -152,7 +166,7 @@ func (pm *ParamGetter) QueryBool(name string) bool {
 	} else if str.EqFold(param, "false", "off") {
 		return false
 	}
-	pm.errs[name] = errors.New("invalid string for bool param")
+	pm.fail(name, errors.New("invalid string for bool param"))
 	return false
 }
 
@@ -221,64 +235,59 @@ func (pm *ParamGetter) QueryBoolOr(name string, def bool) bool {
 func (pm *ParamGetter) QueryJSON(name string, out interface{}) *ParamGetter {
 	val := pm.etx.QueryParam(name)
 	if len(val) == 0 {
-		pm.errs[name] = errors.New("could not find json param")
+		pm.fail(name, errors.New("could not find json param"))
 		return pm
 	}
 	decoded, err := url.PathUnescape(val)
 	if err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 		return pm
 	}
 	if err = json.Unmarshal([]byte(decoded), out); err != nil {
-		pm.errs[name] = err
+		pm.fail(name, err)
 		return pm
 	}
 	return pm
 }
 
 func (pm *ParamGetter) HasError() bool {
-	return len(pm.errs) != 0
+	return pm.errs.Len() != 0
 }
 
 func (pm *ParamGetter) Error() error {
-	if len(pm.errs) == 0 {
+	if pm.errs.Len() == 0 {
 		return nil
 	}
-	buf := bytes.NewBufferString("http parameter error: ")
-	index := 0
-	for k := range pm.errs {
-		buf.WriteString(k)
-		if index < len(pm.errs)-1 {
-			buf.WriteString(", ")
+	names := make([]string, 0, pm.errs.Len())
+	for _, err := range pm.errs.Errors() {
+		if name := paramOf(err); !slices.Contains(names, name) {
+			names = append(names, name)
 		}
-		index++
 	}
-	return errx.Errf(ErrHttpParam, buf.String())
+	return errx.Errf(ErrHttpParam,
+		"http parameter error: %s", strings.Join(names, ", "))
 }
 
+// BadReqError - bad request error with the reason for each invalid param,
+// the reasons are sent to the client along with the error message
 func (pm *ParamGetter) BadReqError() error {
-	if len(pm.errs) == 0 {
+	if pm.errs.Len() == 0 {
 		return nil
 	}
-	buf := bytes.NewBufferString("http parameter error: ")
-	index := 0
-	for k := range pm.errs {
-		buf.WriteString(k)
-		if index < len(pm.errs)-1 {
-			buf.WriteString(", ")
-		}
-		index++
+	ve := &ValidationError{}
+	for _, err := range pm.errs.Errors() {
+		ve.add(paramOf(err), "", err.Error())
 	}
-	// return errx.Errf(ErrHttpParam, buf.String())
-	return errx.BadReq(buf.String())
+	return newValidationHTTPError(ve)
 }
 
 func (pm *ParamGetter) WriteDetailedError(w io.Writer) {
-	for p, e := range pm.errs {
+	for _, err := range pm.errs.Errors() {
+		p := paramOf(err)
 		if len(p) > 15 {
 			p = p[:15]
 		}
-		fmt.Fprintf(w, "%-15s  %v", p, e)
+		fmt.Fprintf(w, "%-15s  %v", p, err)
 	}
 }
 
@@ -0,0 +1,167 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/varunamachi/libx/errx"
+)
+
+// MIMEApplicationProblemJSON - content type of RFC 7807 error responses
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ProblemTypeBase - prefix for the 'type' member of problem responses. The
+// error code is appended to it. Problems without a registered code use
+// 'about:blank' as mandated by RFC 7807
+var ProblemTypeBase = "urn:libx:problem:"
+
+// Problem - RFC 7807 error response. Code, TraceId and Fields are extension
+// members
+type Problem struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Status   int           `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Instance string        `json:"instance,omitempty"`
+	Code     string        `json:"code,omitempty"`
+	TraceId  string        `json:"traceId,omitempty"`
+	Fields   []*FieldError `json:"fields,omitempty"`
+}
+
+// newProblem - creates problem description for an error returned by a
+// handler. Status given by an echo.HTTPError takes precedence over the status
+// of a registered code found in the error chain
+func newProblem(etx echo.Context, err error) *Problem {
+	prob := &Problem{
+		Type:     "about:blank",
+		Status:   http.StatusInternalServerError,
+		Instance: etx.Request().URL.Path,
+		TraceId:  traceId(etx),
+	}
+
+	var httpErr *echo.HTTPError
+	isHttpErr := errors.As(err, &httpErr)
+	if isHttpErr {
+		prob.Status = httpErr.Code
+		prob.Detail = StrMsg(httpErr)
+	}
+
+	var irr *errx.Error
+	if errors.As(err, &irr) {
+		prob.Code = irr.Code
+		if prob.Detail == "" {
+			prob.Detail = irr.Msg
+		}
+	}
+
+	if ec := errx.Lookup(err); ec != nil {
+		prob.Code = ec.Code
+		prob.Title = ec.Title
+		prob.Type = ProblemTypeBase + ec.Code
+		if !isHttpErr {
+			prob.Status = ec.Status
+		}
+		if prob.Detail == "" {
+			prob.Detail = ec.Message
+		}
+	}
+
+	if prob.Title == "" {
+		prob.Title = http.StatusText(prob.Status)
+	}
+	if prob.Code == "" {
+		prob.Code = strconv.Itoa(prob.Status)
+	}
+	if prob.Detail == "" {
+		prob.Detail = err.Error()
+	}
+
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		prob.Fields = ve.Fields
+	}
+	return prob
+}
+
+// traceId - request id sent by the client or set by a middleware, a new one
+// is generated and set in the response header if there is none
+func traceId(etx echo.Context) string {
+	id := etx.Response().Header().Get(echo.HeaderXRequestID)
+	if id != "" {
+		return id
+	}
+	id = etx.Request().Header.Get(echo.HeaderXRequestID)
+	if id == "" {
+		id = uuid.NewString()
+	}
+	etx.Response().Header().Set(echo.HeaderXRequestID, id)
+	return id
+}
+
+func sendProblem(etx echo.Context, prob *Problem) error {
+	if etx.Response().Committed {
+		return nil
+	}
+	body, err := json.Marshal(prob)
+	if err != nil {
+		return err
+	}
+	if etx.Request().Method == http.MethodHead {
+		return etx.NoContent(prob.Status)
+	}
+	return etx.Blob(prob.Status, MIMEApplicationProblemJSON, body)
+}
+
+// toError - converts a problem received from a server to an error. If the
+// code is registered, the error wraps the sentinel for it so that errors.Is
+// works across the wire, otherwise given fallback is wrapped. The error
+// matches the fallback in either case
+func (prob *Problem) toError(fallback error) error {
+	inner := fallback
+	if ec := errx.LookupCode(prob.Code); ec != nil {
+		inner = ec.Err()
+	}
+	msg := prob.Detail
+	if msg == "" {
+		msg = prob.Title
+	}
+	if prob.TraceId != "" {
+		msg += " (trace: " + prob.TraceId + ")"
+	}
+	ce := &clientError{err: inner, fallback: fallback, status: prob.Status}
+	return errx.Errfx(ce, prob.Code, "%d - %s", prob.Status, msg)
+}
+
+// clientError - error received from server, matches ErrClientError and the
+// status based fallback in addition to the wrapped error
+type clientError struct {
+	err      error
+	fallback error
+	status   int
+}
+
+func init() {
+	errx.RegisterClassifier(func(err error) errx.Kind {
+		if ce, ok := err.(*clientError); ok {
+			return errx.StatusKind(ce.status)
+		}
+		return 0
+	})
+}
+
+func (ce *clientError) Error() string {
+	return ce.err.Error()
+}
+
+func (ce *clientError) Unwrap() error {
+	return ce.err
+}
+
+func (ce *clientError) Is(target error) bool {
+	return target == ErrClientError ||
+		(ce.fallback != nil && target == ce.fallback)
+}
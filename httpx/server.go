@@ -2,16 +2,24 @@ package httpx
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
 	"github.com/varunamachi/libx/auth"
 	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -53,8 +61,41 @@ type Server struct {
 	printAllAccess  bool
 	bindIP          string
 	printEndpoints  bool
+	opts            ServerOpts
+	tlsOpts         *TLSOpts
+	cancel          context.CancelFunc
+	mutex           sync.Mutex
+	ready           atomic.Bool
 }
 
+// ServerOpts - options for the underlying HTTP server. Zero values mean no
+// limit or the net/http default
+type ServerOpts struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// ShutdownTimeout - max time to wait for in-flight requests to finish
+	// when the server is shutdown, DefaultShutdownTimeout is used when zero
+	ShutdownTimeout time.Duration
+
+	// DrainDelay - time to keep serving after the server is marked not ready
+	// and before it stops accepting connections, so that load balancers can
+	// see the failing readiness probe and stop sending new requests. The
+	// delay is part of the shutdown timeout
+	DrainDelay time.Duration
+
+	// H2C - serve HTTP/2 without TLS, ignored when TLS is enabled since
+	// HTTP/2 is negotiated using ALPN in that case
+	H2C bool
+}
+
+// DefaultShutdownTimeout - default time given to each phase of graceful
+// shutdown i.e draining of in-flight requests and running of shutdown hooks
+const DefaultShutdownTimeout = 15 * time.Second
+
 func NewServer(printer io.Writer, userGetter auth.UserRetriever) *Server {
 	if printer == nil {
 		printer = &noopWriter{}
@@ -101,30 +142,196 @@ func (s *Server) PrintAllAccess(enable bool) *Server {
 	return s
 }
 
+func (s *Server) WithOpts(opts ServerOpts) *Server {
+	s.opts = opts
+	return s
+}
+
+// WithTLS - serve using TLS, see TLSOpts for details
+func (s *Server) WithTLS(opts *TLSOpts) *Server {
+	s.tlsOpts = opts
+	return s
+}
+
 func (s *Server) Start(port uint32) error {
+	addr := fmt.Sprintf("%s:%d", s.bindIP, port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errx.Errf(err, "failed to listen on '%s'", addr)
+	}
+	return s.Serve(listener)
+}
+
+// StartContext - starts the server and gracefully shuts it down when the
+// context is done. Returns only after in-flight requests are drained
+func (s *Server) StartContext(gtx context.Context, port uint32) error {
+	done := s.shutdownOnDone(gtx)
+	return s.waitShutdown(s.Start(port), done)
+}
+
+// StartTLS - starts a HTTPS server with given certificate and key files,
+// which are reloaded when changed. Use WithTLS for more options
+func (s *Server) StartTLS(port uint32, certFile, keyFile string) error {
+	if s.tlsOpts == nil {
+		s.tlsOpts = &TLSOpts{}
+	}
+	s.tlsOpts.CertFile, s.tlsOpts.KeyFile = certFile, keyFile
+	return s.Start(port)
+}
+
+func (s *Server) StartTLSContext(
+	gtx context.Context, port uint32, certFile, keyFile string) error {
+	done := s.shutdownOnDone(gtx)
+	return s.waitShutdown(s.StartTLS(port, certFile, keyFile), done)
+}
+
+// Serve - serves on the given listener instead of creating a TCP listener,
+// for example to listen on a unix domain socket or on a socket passed by
+// systemd (see netx.SystemdListeners)
+func (s *Server) Serve(listener net.Listener) error {
 	s.configure()
 
 	if s.printEndpoints {
 		s.Print()
 	}
-	addr := fmt.Sprintf("%s:%d", s.bindIP, port)
-	if err := s.echo.Start(addr); err != nil {
+
+	gtx, cancel := context.WithCancel(context.Background())
+	s.mutex.Lock()
+	s.cancel = cancel
+	s.mutex.Unlock()
+	defer cancel()
+
+	srv := s.echo.Server
+	srv.Handler = s.echo
+	srv.ReadTimeout = s.opts.ReadTimeout
+	srv.ReadHeaderTimeout = s.opts.ReadHeaderTimeout
+	srv.WriteTimeout = s.opts.WriteTimeout
+	srv.IdleTimeout = s.opts.IdleTimeout
+	srv.MaxHeaderBytes = s.opts.MaxHeaderBytes
+
+	if s.tlsOpts != nil {
+		cfg, err := s.tlsOpts.tlsConfig(gtx)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		srv.TLSConfig = cfg
+		listener = tls.NewListener(listener, cfg)
+		s.echo.TLSListener = listener
+	} else {
+		if s.opts.H2C {
+			srv.Handler = h2c.NewHandler(s.echo, &http2.Server{
+				IdleTimeout: s.opts.IdleTimeout,
+			})
+		}
+		s.echo.Listener = listener
+	}
+
+	log.Info().
+		Str("address", listener.Addr().String()).
+		Bool("tls", s.tlsOpts != nil).
+		Msg("server started")
+	s.ready.Store(true)
+	if err := srv.Serve(listener); err != nil {
 		return errx.Wrap(err)
 	}
-	log.Info().Uint32("port", port).Msg("server started")
 	return nil
 }
 
-func (s *Server) StartContext(gtx context.Context, port uint32) error {
+func (s *Server) ServeContext(
+	gtx context.Context, listener net.Listener) error {
+	done := s.shutdownOnDone(gtx)
+	return s.waitShutdown(s.Serve(listener), done)
+}
 
+func (s *Server) shutdownOnDone(gtx context.Context) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		<-gtx.Done()
-		fmt.Println("\nstopping server gracefully")
-		if err := s.Close(); err != nil {
-			log.Error().Err(err).Msg("failed to stop exec-server")
+		log.Info().Msg("stopping server gracefully")
+
+		timeout := data.NonEmpty(s.opts.ShutdownTimeout, DefaultShutdownTimeout)
+		stx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := s.Shutdown(stx); err != nil {
+			log.Error().Err(err).Msg("failed to stop server gracefully")
 		}
 	}()
-	return s.Start(port)
+	return done
+}
+
+// waitShutdown - Serve returns as soon as shutdown begins, so wait for
+// draining to finish. If serving failed for any other reason there is nothing
+// to wait for
+func (s *Server) waitShutdown(err error, done <-chan struct{}) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		<-done
+	}
+	return err
+}
+
+// Shutdown - marks the server as not ready, waits for DrainDelay, stops
+// accepting new connections and waits for in-flight requests to finish until
+// the context expires. The remaining connections are closed forcefully after
+// that
+func (s *Server) Shutdown(gtx context.Context) error {
+	s.ready.Store(false)
+	if s.opts.DrainDelay > 0 {
+		log.Info().Dur("delay", s.opts.DrainDelay).
+			Msg("waiting before draining requests")
+		select {
+		case <-gtx.Done():
+		case <-time.After(s.opts.DrainDelay):
+		}
+	}
+
+	s.mutex.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mutex.Unlock()
+
+	if err := s.echo.Shutdown(gtx); err != nil {
+		if cerr := s.echo.Close(); cerr != nil {
+			log.Error().Err(cerr).Msg("failed to close server connections")
+		}
+		return errx.Errf(err, "failed to drain in-flight requests")
+	}
+	return nil
+}
+
+// Ready - tells if the server is serving and is not shutting down
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// WithProbes - adds /liveness and /readiness endpoints. Readiness fails with
+// 503 as soon as the server starts shutting down
+func (s *Server) WithProbes() *Server {
+	return s.WithPages(
+		&Endpoint{
+			Method:   echo.GET,
+			Path:     "/liveness",
+			Category: "probes",
+			Desc:     "Liveness probe",
+			Handler: func(etx echo.Context) error {
+				return etx.NoContent(http.StatusOK)
+			},
+		},
+		&Endpoint{
+			Method:   echo.GET,
+			Path:     "/readiness",
+			Category: "probes",
+			Desc:     "Readiness probe",
+			Handler: func(etx echo.Context) error {
+				if !s.Ready() {
+					return etx.NoContent(http.StatusServiceUnavailable)
+				}
+				return etx.NoContent(http.StatusOK)
+			},
+		},
+	)
 }
 
 func (s *Server) configure() {
@@ -227,6 +434,12 @@ func (s *Server) Print() {
 }
 
 func (s *Server) Close() error {
+	s.ready.Store(false)
+	s.mutex.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mutex.Unlock()
 	return s.echo.Close()
 }
 
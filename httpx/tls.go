@@ -0,0 +1,172 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/auth"
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidClientCA = errors.New("httpx.tls.invalidClientCA")
+)
+
+// DefaultCertReloadInterval - how often certificate files are checked for
+// modification
+const DefaultCertReloadInterval = 30 * time.Second
+
+// ClientCertMapper - maps a verified client certificate to a user, used for
+// authorizing endpoints when mutual TLS is enabled
+type ClientCertMapper interface {
+	UserFromCert(gtx context.Context, cert *x509.Certificate) (auth.User, error)
+}
+
+// TLSOpts - TLS configuration for the server. Certificate and key files are
+// reloaded when they change on disk. If ClientCAFile is given, client
+// certificates are requested and verified against it
+type TLSOpts struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ClientAuth     tls.ClientAuthType
+	ReloadInterval time.Duration
+	CertMapper     ClientCertMapper
+}
+
+type certReloader struct {
+	mutex    sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	certStat, err := os.Stat(cr.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyStat, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyStat.ModTime().After(certStat.ModTime()) {
+		return keyStat.ModTime(), nil
+	}
+	return certStat.ModTime(), nil
+}
+
+func (cr *certReloader) load() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return errx.Errf(err, "failed to stat certificate files")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errx.Errf(err, "failed to load certificate '%s' and key '%s'",
+			cr.certFile, cr.keyFile)
+	}
+
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+func (cr *certReloader) watch(gtx context.Context, interval time.Duration) {
+	tkr := time.NewTicker(interval)
+	defer tkr.Stop()
+	for {
+		select {
+		case <-gtx.Done():
+			return
+		case <-tkr.C:
+		}
+
+		modTime, err := cr.latestModTime()
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to stat certificate files")
+			continue
+		}
+		cr.mutex.RLock()
+		changed := modTime.After(cr.modTime)
+		cr.mutex.RUnlock()
+		if !changed {
+			continue
+		}
+
+		// Cert and key might be replaced one after the other, a failed load
+		// keeps the old certificate and is retried on next tick
+		if err := cr.load(); err != nil {
+			log.Warn().Err(err).Msg("failed to reload certificate")
+			continue
+		}
+		log.Info().Str("cert", cr.certFile).Msg("certificate reloaded")
+	}
+}
+
+func (cr *certReloader) getCertificate(
+	*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.cert, nil
+}
+
+func (opts *TLSOpts) tlsConfig(
+	gtx context.Context) (*tls.Config, error) {
+	reloader, err := newCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	interval := opts.ReloadInterval
+	if interval <= 0 {
+		interval = DefaultCertReloadInterval
+	}
+	go reloader.watch(gtx, interval)
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, errx.Errf(err, "failed to read client CA file '%s'",
+				opts.ClientCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errx.Errf(ErrInvalidClientCA,
+				"no valid certificates in client CA file '%s'",
+				opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = opts.ClientAuth
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
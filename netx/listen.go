@@ -0,0 +1,126 @@
+package netx
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+var (
+	ErrInvalidSocketPath  = errors.New("listen.socket.invalidPath")
+	ErrNoSystemdListeners = errors.New("listen.systemd.noListeners")
+)
+
+// sdListenFdsStart - first file descriptor passed by systemd socket activation
+const sdListenFdsStart = 3
+
+// ListenUnix - listens on unix domain socket at given path and restricts
+// access to it using given file mode. The socket is created inside a private
+// directory and moved to the path only after its mode is set, so it is never
+// accessible with looser permissions. Stale socket files are removed
+func ListenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	stat, err := os.Lstat(path)
+	if err == nil {
+		if stat.Mode().Type() != fs.ModeSocket {
+			return nil, errx.Errf(ErrInvalidSocketPath,
+				"'%s' exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, errx.Errf(err, "failed to remove stale socket")
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errx.Errf(err, "failed to create dir for socket")
+	}
+	tmpDir, err := os.MkdirTemp(dir, ".sock-")
+	if err != nil {
+		return nil, errx.Errf(err, "failed to create temp dir for socket")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, filepath.Base(path))
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to listen on '%s'", path)
+	}
+	ul := listener.(*net.UnixListener)
+	// Listener would otherwise unlink the temp path on close
+	ul.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		ul.Close()
+		return nil, errx.Errf(err, "failed to set mode of '%s'", path)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		ul.Close()
+		return nil, errx.Errf(err, "failed to move socket to '%s'", path)
+	}
+	return &unixListener{UnixListener: ul, path: path}, nil
+}
+
+// unixListener - removes the socket file at its final path on close
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (ul *unixListener) Close() error {
+	err := ul.UnixListener.Close()
+	if rerr := os.Remove(ul.path); rerr != nil && err == nil &&
+		!errors.Is(rerr, fs.ErrNotExist) {
+		err = rerr
+	}
+	return err
+}
+
+// SystemdListeners - gives the listeners passed by systemd socket activation,
+// keyed by the names from LISTEN_FDNAMES. Unnamed sockets are keyed by their
+// index. The environment variables are unset so that child processes do not
+// inherit them
+func SystemdListeners() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errx.Errf(ErrNoSystemdListeners,
+			"no sockets passed by systemd to this process")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, errx.Errf(ErrNoSystemdListeners,
+			"no sockets passed by systemd to this process")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	out := make(map[string]net.Listener, count)
+	for idx := 0; idx < count; idx++ {
+		name := strconv.Itoa(idx)
+		if idx < len(names) && names[idx] != "" {
+			name = names[idx]
+		}
+
+		file := os.NewFile(uintptr(sdListenFdsStart+idx), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, created := range out {
+				created.Close()
+			}
+			return nil, errx.Errf(err,
+				"failed to create listener from systemd socket '%s'", name)
+		}
+		out[name] = listener
+	}
+	return out, nil
+}
@@ -1,31 +1,57 @@
 package proc
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
 	"os/exec"
+	"sync"
+	"time"
 
 	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
 )
 
 // TODO - add way to start command in background
-// TODO - simple way to get output as string
 
 var (
 	ErrCmdBuilderAlreadyUsed = errors.New("command builder already used")
+	ErrCmdTimedOut           = errors.New("proc.cmd.timedOut")
+	ErrCmdFailed             = errors.New("proc.cmd.failed")
+	ErrEmptyPipeline         = errors.New("proc.pipeline.empty")
 )
 
+// StderrTailSize - number of bytes from the end of stderr that are kept in
+// the CmdResult
+var StderrTailSize = 2048
+
+// killGracePeriod - time given for I/O to finish after the process group is
+// killed because of cancellation or timeout
+const killGracePeriod = 2 * time.Second
+
+// CmdResult - outcome of running a command
+type CmdResult struct {
+	ExitCode   int           `json:"exitCode"`
+	Duration   time.Duration `json:"duration"`
+	StderrTail string        `json:"stderrTail"`
+	TimedOut   bool          `json:"timedOut"`
+}
+
 type CmdBuilder struct {
 	Cmd  string
 	Env  map[string]string
 	Args []string
+	Dir  string
 
-	done   bool
-	stdout io.Writer
-	stdin  io.Reader
-	stderr io.Writer
+	done       bool
+	clearEnv   bool
+	timeout    time.Duration
+	stdout     io.Writer
+	stdin      io.Reader
+	stderr     io.Writer
+	stderrTail *tailBuffer
 }
 
 func NewCmdBuilder(cmd string) *CmdBuilder {
@@ -46,6 +72,14 @@ func (cb *CmdBuilder) WithEnv(name, value string) *CmdBuilder {
 	return cb
 }
 
+// InheritEnv - decides if the parent process's environment is passed to the
+// command. Env vars set with WithEnv are added on top of it. By default the
+// environment is inherited
+func (cb *CmdBuilder) InheritEnv(inherit bool) *CmdBuilder {
+	cb.clearEnv = !inherit
+	return cb
+}
+
 func (cb *CmdBuilder) WithArgs(args ...string) *CmdBuilder {
 	if cb.Args == nil {
 		cb.Args = args
@@ -55,6 +89,19 @@ func (cb *CmdBuilder) WithArgs(args ...string) *CmdBuilder {
 	return cb
 }
 
+func (cb *CmdBuilder) WithDir(dir string) *CmdBuilder {
+	cb.Dir = dir
+	return cb
+}
+
+// WithTimeout - kills the command along with any process it has spawned if
+// it does not finish within given duration. Only applies to the context
+// aware methods
+func (cb *CmdBuilder) WithTimeout(timeout time.Duration) *CmdBuilder {
+	cb.timeout = timeout
+	return cb
+}
+
 func (cb *CmdBuilder) WithOutput(out, err io.Writer) *CmdBuilder {
 	cb.stdout, cb.stderr = out, err
 	return cb
@@ -72,12 +119,7 @@ func (cb *CmdBuilder) WithInput(in io.Reader) *CmdBuilder {
 
 func (cb *CmdBuilder) Command() *exec.Cmd {
 	cmd := exec.Command(cb.Cmd, cb.Args...)
-	for k, v := range cb.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
-	}
-	cmd.Stdout = data.Qop(cb.stdout != nil, cb.stdout, io.Writer(os.Stdout))
-	cmd.Stderr = data.Qop(cb.stderr != nil, cb.stderr, io.Writer(os.Stderr))
-	cmd.Stdin = data.Qop(cb.stdin != nil, cb.stdin, io.Reader(os.Stdin))
+	cb.configure(cmd)
 	return cmd
 }
 
@@ -109,11 +151,174 @@ func (cb *CmdBuilder) Start() (*os.Process, error) {
 	return cmd.Process, nil
 }
 
+// RunContext - runs the command and waits for it to finish. If the context is
+// cancelled or the timeout expires, the whole process group is killed
+func (cb *CmdBuilder) RunContext(gtx context.Context) (*CmdResult, error) {
+	if cb.done {
+		return nil, errx.Errf(ErrCmdBuilderAlreadyUsed,
+			"this command builder has already been used")
+	}
+	cb.done = true
+
+	gtx, cancel := cb.withTimeout(gtx)
+	defer cancel()
+
+	cmd := cb.commandContext(gtx)
+	start := time.Now()
+	err := cmd.Run()
+	return cb.result(gtx, cmd, start, err)
+}
+
+// Output - runs the command and returns whatever it wrote to stdout. Stderr
+// goes to the writer given with WithOutput or to os.Stderr, its tail is
+// available in the result in any case
+func (cb *CmdBuilder) Output(
+	gtx context.Context) ([]byte, *CmdResult, error) {
+	var buf bytes.Buffer
+	cb.stdout = &buf
+	res, err := cb.RunContext(gtx)
+	return buf.Bytes(), res, err
+}
+
+// CombinedOutput - runs the command and returns the interleaved stdout and
+// stderr output
+func (cb *CmdBuilder) CombinedOutput(
+	gtx context.Context) ([]byte, *CmdResult, error) {
+	var buf bytes.Buffer
+	cb.stdout, cb.stderr = &buf, &buf
+	res, err := cb.RunContext(gtx)
+	return buf.Bytes(), res, err
+}
+
 func (cb *CmdBuilder) ToCmdDesc(name string) *CmdDesc {
 	return &CmdDesc{
-		Name: name,
-		Path: cb.Cmd,
-		Args: cb.Args,
-		Env:  cb.Env,
+		Name:          name,
+		Path:          cb.Cmd,
+		Args:          cb.Args,
+		Env:           cb.Env,
+		Cwd:           cb.Dir,
+		EnvsForwarded: cb.clearEnv,
+	}
+}
+
+func (cb *CmdBuilder) configure(cmd *exec.Cmd) {
+	if cb.clearEnv || len(cb.Env) != 0 {
+		// Setting cmd.Env replaces the parent env entirely, so when not
+		// cleared explicitly, the parent env needs to be copied first
+		base := data.Qop(cb.clearEnv, []string{}, os.Environ())
+		cmd.Env = make([]string, 0, len(base)+len(cb.Env))
+		cmd.Env = append(cmd.Env, base...)
+		for k, v := range cb.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	cmd.Dir = cb.Dir
+
+	cmd.Stdout = data.Qop(cb.stdout != nil, cb.stdout, io.Writer(os.Stdout))
+	cmd.Stderr = data.Qop(cb.stderr != nil, cb.stderr, io.Writer(os.Stderr))
+	cmd.Stdin = data.Qop(cb.stdin != nil, cb.stdin, io.Reader(os.Stdin))
+}
+
+func (cb *CmdBuilder) commandContext(gtx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(gtx, cb.Cmd, cb.Args...)
+	cb.configure(cmd)
+
+	// exec only serializes writes when Stdout and Stderr are the same writer,
+	// wrapping stderr with the tail buffer breaks that, hence the lock
+	if cmd.Stdout == cmd.Stderr {
+		lw := &lockedWriter{inner: cmd.Stdout}
+		cmd.Stdout, cmd.Stderr = lw, lw
+	}
+	cb.stderrTail = newTailBuffer(StderrTailSize)
+	cmd.Stderr = io.MultiWriter(cmd.Stderr, cb.stderrTail)
+
+	setProcGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcGroup(cmd)
+	}
+	cmd.WaitDelay = killGracePeriod
+	return cmd
+}
+
+func (cb *CmdBuilder) withTimeout(
+	gtx context.Context) (context.Context, context.CancelFunc) {
+	if cb.timeout > 0 {
+		return context.WithTimeout(gtx, cb.timeout)
+	}
+	return context.WithCancel(gtx)
+}
+
+func (cb *CmdBuilder) result(
+	gtx context.Context,
+	cmd *exec.Cmd,
+	start time.Time,
+	err error) (*CmdResult, error) {
+
+	res := &CmdResult{
+		ExitCode: -1,
+		Duration: time.Since(start),
+		TimedOut: errors.Is(gtx.Err(), context.DeadlineExceeded),
+	}
+	if cb.stderrTail != nil {
+		res.StderrTail = cb.stderrTail.String()
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if err == nil {
+		return res, nil
+	}
+	if res.TimedOut {
+		return res, errx.Errf(ErrCmdTimedOut,
+			"command '%s' timed out after %v",
+			cb.Cmd, res.Duration.Round(time.Millisecond))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return res, errx.Errf(ErrCmdFailed,
+			"command '%s' exited with code %d", cb.Cmd, res.ExitCode)
+	}
+	return res, errx.Errf(err, "failed to run command '%s'", cb.Cmd)
+}
+
+type lockedWriter struct {
+	mutex sync.Mutex
+	inner io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+	return lw.inner.Write(p)
+}
+
+// tailBuffer - writer that only keeps the last N bytes written to it
+type tailBuffer struct {
+	buf  []byte
+	size int
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{
+		buf:  make([]byte, 0, size),
+		size: size,
 	}
 }
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n >= tb.size {
+		tb.buf = append(tb.buf[:0], p[n-tb.size:]...)
+		return n, nil
+	}
+	if over := len(tb.buf) + n - tb.size; over > 0 {
+		tb.buf = append(tb.buf[:0], tb.buf[over:]...)
+	}
+	tb.buf = append(tb.buf, p...)
+	return n, nil
+}
+
+func (tb *tailBuffer) String() string {
+	return string(tb.buf)
+}
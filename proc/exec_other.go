@@ -0,0 +1,16 @@
+//go:build !unix
+
+package proc
+
+import (
+	"os/exec"
+)
+
+func setProcGroup(cmd *exec.Cmd) {}
+
+func killProcGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
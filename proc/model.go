@@ -18,10 +18,12 @@ type CmdEntry struct {
 	command *exec.Cmd
 	desc    *CmdDesc
 	started time.Time
+	stats   *statsHistory
 }
 
 type CmdInfo struct {
 	Desc    *CmdDesc
 	Started time.Time
 	PID     int
+	Stats   *ProcStats
 }
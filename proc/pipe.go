@@ -0,0 +1,140 @@
+package proc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+// Pipeline - set of commands where stdout of each command is connected to the
+// stdin of the next one, without involving a shell
+type Pipeline struct {
+	stages  []*CmdBuilder
+	timeout time.Duration
+	done    bool
+}
+
+// Pipe - creates a pipeline out of given command builders. Stdin of the first
+// and stdout of the last command are taken from the respective builders,
+// stderr of every command is kept as configured in its builder
+func Pipe(cbs ...*CmdBuilder) *Pipeline {
+	return &Pipeline{
+		stages: cbs,
+	}
+}
+
+// WithTimeout - kills all the commands in the pipeline if the pipeline does
+// not finish within given duration. Timeouts of the individual command
+// builders are honoured as well, see Run
+func (pl *Pipeline) WithTimeout(timeout time.Duration) *Pipeline {
+	pl.timeout = timeout
+	return pl
+}
+
+// Run - runs the pipeline and returns a result for each command. Like
+// 'pipefail' in bash, the pipeline fails if any of the commands fail; the
+// error is from the first failed command. A command with a timeout of its
+// own is killed when that expires, the rest of the pipeline then sees the
+// closed pipe as usual
+func (pl *Pipeline) Run(gtx context.Context) ([]*CmdResult, error) {
+	if pl.done {
+		return nil, errx.Errf(ErrCmdBuilderAlreadyUsed,
+			"this pipeline has already been used")
+	}
+	if len(pl.stages) == 0 {
+		return nil, errx.Errf(ErrEmptyPipeline, "no commands in pipeline")
+	}
+	for _, cb := range pl.stages {
+		if cb.done {
+			return nil, errx.Errf(ErrCmdBuilderAlreadyUsed,
+				"command builder for '%s' has already been used", cb.Cmd)
+		}
+	}
+	pl.done = true
+
+	var cancel context.CancelFunc
+	if pl.timeout > 0 {
+		gtx, cancel = context.WithTimeout(gtx, pl.timeout)
+	} else {
+		gtx, cancel = context.WithCancel(gtx)
+	}
+	defer cancel()
+
+	cmds := make([]*exec.Cmd, len(pl.stages))
+	stxs := make([]context.Context, len(pl.stages))
+	for idx, cb := range pl.stages {
+		cb.done = true
+		stx, scancel := cb.withTimeout(gtx)
+		defer scancel()
+		stxs[idx] = stx
+		cmds[idx] = cb.commandContext(stx)
+	}
+
+	// The parent's copies of the pipe ends have to be closed once the
+	// children have them, otherwise readers never see EOF
+	pipes := make([]*os.File, 0, 2*(len(cmds)-1))
+	closePipes := func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+		pipes = pipes[:0]
+	}
+	defer closePipes()
+
+	for idx := 0; idx < len(cmds)-1; idx++ {
+		rd, wr, err := os.Pipe()
+		if err != nil {
+			return nil, errx.Errf(err, "failed to create pipe")
+		}
+		pipes = append(pipes, rd, wr)
+		cmds[idx].Stdout = wr
+		cmds[idx+1].Stdin = rd
+	}
+
+	start := time.Now()
+	started := 0
+	var startErr error
+	for _, cmd := range cmds {
+		if startErr = cmd.Start(); startErr != nil {
+			break
+		}
+		started++
+	}
+	closePipes()
+
+	if startErr != nil {
+		cancel()
+		for _, cmd := range cmds[:started] {
+			_ = cmd.Wait()
+		}
+		return nil, errx.Errf(startErr,
+			"failed to start '%s' in pipeline", pl.stages[started].Cmd)
+	}
+
+	results := make([]*CmdResult, len(cmds))
+	var firstErr error
+	for idx, cmd := range cmds {
+		res, err := pl.stages[idx].result(stxs[idx], cmd, start, cmd.Wait())
+		results[idx] = res
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+// Output - runs the pipeline and returns the stdout of the last command
+func (pl *Pipeline) Output(
+	gtx context.Context) ([]byte, []*CmdResult, error) {
+	if len(pl.stages) == 0 {
+		return nil, nil, errx.Errf(ErrEmptyPipeline, "no commands in pipeline")
+	}
+	var buf bytes.Buffer
+	pl.stages[len(pl.stages)-1].stdout = &buf
+	res, err := pl.Run(gtx)
+	return buf.Bytes(), res, err
+}
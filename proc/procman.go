@@ -27,18 +27,40 @@ var (
 )
 
 type Manager struct {
-	mutex sync.Mutex
-	gtx   context.Context
-	cmds  map[string]CmdEntry
+	mutex        sync.Mutex
+	gtx          context.Context
+	cmds         map[string]CmdEntry
+	historySize  int
+	statsEnabled bool
 }
 
 func NewManager(gtx context.Context) *Manager {
 	return &Manager{
-		gtx:  gtx,
-		cmds: map[string]CmdEntry{},
+		gtx:         gtx,
+		cmds:        map[string]CmdEntry{},
+		historySize: DefaultStatsHistorySize,
 	}
 }
 
+// WithStats - periodically sample resource usage of managed processes at
+// the given interval and keep the last historySize samples for each of them
+func (man *Manager) WithStats(
+	interval time.Duration, historySize int) *Manager {
+	man.mutex.Lock()
+	if man.statsEnabled {
+		man.mutex.Unlock()
+		return man
+	}
+	man.statsEnabled = true
+	man.historySize = data.Qop(
+		historySize > 0, historySize, DefaultStatsHistorySize)
+	man.mutex.Unlock()
+
+	interval = data.Qop(interval > 0, interval, DefaultStatsInterval)
+	go man.sampleLoop(interval)
+	return man
+}
+
 func (man *Manager) Add(cdesc *CmdDesc) (int, error) {
 	existing := man.Get(cdesc.Name)
 	if existing != nil {
@@ -103,8 +125,15 @@ func (man *Manager) Terminate(name string, forceKill bool) error {
 
 func (man *Manager) TerminateAll(forceKill bool) error {
 
-	signal := data.Qop(forceKill, os.Kill, os.Interrupt)
+	man.mutex.Lock()
+	entries := make([]CmdEntry, 0, len(man.cmds))
 	for _, value := range man.cmds {
+		entries = append(entries, value)
+	}
+	man.mutex.Unlock()
+
+	signal := data.Qop(forceKill, os.Kill, os.Interrupt)
+	for _, value := range entries {
 
 		cmd := value.command
 
@@ -157,6 +186,7 @@ func (man *Manager) List() []*CmdInfo {
 			Desc:    val.desc,
 			Started: val.started,
 			PID:     val.command.Process.Pid,
+			Stats:   val.stats.latest(),
 		})
 	}
 
@@ -169,6 +199,81 @@ func (man *Manager) List() []*CmdInfo {
 	return out
 }
 
+// Stats - gives the latest resource usage sample along with the sample
+// history for the command with given name
+func (man *Manager) Stats(name string) (*CmdStats, error) {
+	man.mutex.Lock()
+	defer man.mutex.Unlock()
+
+	entry, found := man.cmds[name]
+	if !found || entry.command.Process == nil {
+		return nil, errx.Errf(ErrCommandNotFound,
+			"command with name '%s' does not exit", name)
+	}
+	return &CmdStats{
+		Name:    name,
+		PID:     entry.command.Process.Pid,
+		Latest:  entry.stats.latest(),
+		History: entry.stats.history(),
+	}, nil
+}
+
+func (man *Manager) sampleLoop(interval time.Duration) {
+	tkr := time.NewTicker(interval)
+	defer tkr.Stop()
+
+	for {
+		man.sample()
+		select {
+		case <-man.gtx.Done():
+			return
+		case <-tkr.C:
+		}
+	}
+}
+
+func (man *Manager) sample() {
+	pids := map[string]int{}
+	man.mutex.Lock()
+	for name, entry := range man.cmds {
+		if entry.command.Process != nil {
+			pids[name] = entry.command.Process.Pid
+		}
+	}
+	man.mutex.Unlock()
+	if len(pids) == 0 {
+		return
+	}
+
+	children, err := childCounts()
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get child process counts")
+	}
+
+	now := time.Now()
+	raws := make(map[string]*rawStats, len(pids))
+	for name, pid := range pids {
+		raw, err := readRawStats(pid)
+		if err != nil {
+			log.Debug().Err(err).Str("name", name).Int("pid", pid).
+				Msg("failed to sample process stats")
+			continue
+		}
+		raws[name] = raw
+	}
+
+	man.mutex.Lock()
+	defer man.mutex.Unlock()
+	for name, raw := range raws {
+		entry, found := man.cmds[name]
+		if !found || entry.command.Process == nil ||
+			entry.command.Process.Pid != pids[name] {
+			continue
+		}
+		entry.stats.add(now, raw, children[pids[name]])
+	}
+}
+
 func (man *Manager) addToMap(cmd *exec.Cmd, desc *CmdDesc) {
 	man.mutex.Lock()
 	defer man.mutex.Unlock()
@@ -176,6 +281,7 @@ func (man *Manager) addToMap(cmd *exec.Cmd, desc *CmdDesc) {
 		command: cmd,
 		desc:    desc,
 		started: time.Now(),
+		stats:   newStatsHistory(man.historySize),
 	}
 }
 
@@ -0,0 +1,92 @@
+package proc
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrStatsNotSupported = errors.New("proc.stats.notSupported")
+)
+
+const (
+	DefaultStatsInterval    = 2 * time.Second
+	DefaultStatsHistorySize = 30
+)
+
+// ProcStats - single sample of resource usage of a managed process
+type ProcStats struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpuPercent"`
+	RSS        int64     `json:"rss"`
+	OpenFDs    int       `json:"openFds"`
+	Threads    int       `json:"threads"`
+	Children   int       `json:"children"`
+}
+
+// CmdStats - latest sample and a short history of samples for a process
+type CmdStats struct {
+	Name    string       `json:"name"`
+	PID     int          `json:"pid"`
+	Latest  *ProcStats   `json:"latest"`
+	History []*ProcStats `json:"history"`
+}
+
+// rawStats - counters read from the OS, cpu time is cumulative
+type rawStats struct {
+	cpuTime time.Duration
+	rss     int64
+	fds     int
+	threads int
+}
+
+type statsHistory struct {
+	samples  []*ProcStats
+	size     int
+	prevCPU  time.Duration
+	prevTime time.Time
+}
+
+func newStatsHistory(size int) *statsHistory {
+	return &statsHistory{
+		samples: make([]*ProcStats, 0, size),
+		size:    size,
+	}
+}
+
+func (sh *statsHistory) add(now time.Time, raw *rawStats, children int) {
+	cpu := 0.0
+	if !sh.prevTime.IsZero() {
+		wall := now.Sub(sh.prevTime)
+		if wall > 0 {
+			cpu = float64(raw.cpuTime-sh.prevCPU) / float64(wall) * 100
+		}
+	}
+	sh.prevCPU, sh.prevTime = raw.cpuTime, now
+
+	if len(sh.samples) == sh.size {
+		copy(sh.samples, sh.samples[1:])
+		sh.samples = sh.samples[:len(sh.samples)-1]
+	}
+	sh.samples = append(sh.samples, &ProcStats{
+		Time:       now,
+		CPUPercent: cpu,
+		RSS:        raw.rss,
+		OpenFDs:    raw.fds,
+		Threads:    raw.threads,
+		Children:   children,
+	})
+}
+
+func (sh *statsHistory) latest() *ProcStats {
+	if len(sh.samples) == 0 {
+		return nil
+	}
+	return sh.samples[len(sh.samples)-1]
+}
+
+func (sh *statsHistory) history() []*ProcStats {
+	out := make([]*ProcStats, len(sh.samples))
+	copy(out, sh.samples)
+	return out
+}
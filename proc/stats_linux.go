@@ -0,0 +1,103 @@
+//go:build linux
+
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/varunamachi/libx/errx"
+)
+
+// clockTicks - USER_HZ, which is 100 on all mainstream linux architectures.
+// Reading it properly needs sysconf, which needs cgo
+const clockTicks = 100
+
+// statFields - fields from /proc/<pid>/stat that come after the command name,
+// index 0 is the state field (field 3 in proc(5))
+func statFields(pid int) ([]string, error) {
+	path := filepath.Join("/proc", strconv.Itoa(pid), "stat")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Command name is in parens and can contain spaces and parens
+	str := string(content)
+	idx := strings.LastIndexByte(str, ')')
+	if idx < 0 || idx+2 >= len(str) {
+		return nil, errx.Fmt("malformed stat file for pid '%d'", pid)
+	}
+	return strings.Fields(str[idx+2:]), nil
+}
+
+func readRawStats(pid int) (*rawStats, error) {
+	fields, err := statFields(pid)
+	if err != nil {
+		return nil, errx.Errf(err, "failed to read stat for pid '%d'", pid)
+	}
+	if len(fields) < 18 {
+		return nil, errx.Fmt("too few fields in stat for pid '%d'", pid)
+	}
+
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+
+	statm, err := os.ReadFile(
+		filepath.Join("/proc", strconv.Itoa(pid), "statm"))
+	if err != nil {
+		return nil, errx.Errf(err, "failed to read statm for pid '%d'", pid)
+	}
+	rss := int64(0)
+	if mf := strings.Fields(string(statm)); len(mf) > 1 {
+		pages, _ := strconv.ParseInt(mf[1], 10, 64)
+		rss = pages * int64(os.Getpagesize())
+	}
+
+	// Reading fds of a process owned by another user fails, we still want
+	// the other numbers in that case
+	fds := -1
+	ents, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err == nil {
+		fds = len(ents)
+	}
+
+	return &rawStats{
+		cpuTime: time.Duration(utime+stime) * time.Second / clockTicks,
+		rss:     rss,
+		fds:     fds,
+		threads: threads,
+	}, nil
+}
+
+// childCounts - number of direct children for every process on the system,
+// keyed by the parent PID
+func childCounts() (map[int]int, error) {
+	ents, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, errx.Errf(err, "failed to list /proc")
+	}
+
+	counts := map[int]int{}
+	for _, ent := range ents {
+		pid, err := strconv.Atoi(ent.Name())
+		if err != nil || !ent.IsDir() {
+			continue
+		}
+		fields, err := statFields(pid)
+		if err != nil || len(fields) < 2 {
+			// Process might have exited in the meantime
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		counts[ppid]++
+	}
+	return counts, nil
+}
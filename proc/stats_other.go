@@ -0,0 +1,17 @@
+//go:build !linux
+
+package proc
+
+import (
+	"github.com/varunamachi/libx/errx"
+)
+
+func readRawStats(pid int) (*rawStats, error) {
+	return nil, errx.Errf(ErrStatsNotSupported,
+		"process stats are only supported on linux")
+}
+
+func childCounts() (map[int]int, error) {
+	return nil, errx.Errf(ErrStatsNotSupported,
+		"process stats are only supported on linux")
+}
@@ -9,15 +9,23 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Gtx - gives a context that is cancelled when SIGINT or SIGTERM is received.
+// A second signal while the application is shutting down exits the process
+// immediately
 func Gtx() (context.Context, context.CancelFunc) {
 
 	gtx, cancel := context.WithCancel(context.Background())
-	sigs := make(chan os.Signal, 1)
+	sigs := make(chan os.Signal, 2)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		sig := <-sigs
 		log.Warn().Str("signal", sig.String()).Msg("signal received")
 		cancel()
+
+		sig = <-sigs
+		log.Warn().Str("signal", sig.String()).
+			Msg("second signal received, forcing exit")
+		os.Exit(1)
 		// code := 0
 		// if sig == syscall.SIGTERM {
 		// 	code = -1
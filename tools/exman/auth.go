@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/varunamachi/libx/errx"
+	"github.com/varunamachi/libx/iox"
+)
+
+// tokenPath - path of the file in user's config directory where the access
+// token for the running exman server is stored
+func tokenPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errx.Errf(err, "failed to get user config directory")
+	}
+	return filepath.Join(dir, "exman", "token"), nil
+}
+
+// createToken - generates a new random token and stores it in a file that is
+// only readable by current user
+func createToken() (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", errx.Errf(err, "failed to create dir for exman token")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errx.Errf(err, "failed to generate exman token")
+	}
+	token := hex.EncodeToString(buf)
+
+	// Remove first so that permissions of any existing file do not apply
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", errx.Errf(err, "failed to remove old exman token")
+	}
+	if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+		return "", errx.Errf(err, "failed to write exman token")
+	}
+	return token, nil
+}
+
+// readToken - reads token stored by the server, empty string is returned if
+// there is no token
+func readToken() string {
+	path, err := tokenPath()
+	if err != nil || !iox.ExistsAsFile(path) {
+		return ""
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).
+			Msg("failed to read exman token")
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func removeToken() {
+	path, err := tokenPath()
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warn().Err(err).Str("path", path).
+			Msg("failed to remove exman token")
+	}
+}
+
+func tokenMiddleware(token string) echo.MiddlewareFunc {
+	expected := []byte("Bearer " + token)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(etx echo.Context) error {
+			got := []byte(etx.Request().Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(got, expected) != 1 {
+				return &echo.HTTPError{
+					Code:    http.StatusUnauthorized,
+					Message: "invalid or missing exman token",
+				}
+			}
+			return next(etx)
+		}
+	}
+}
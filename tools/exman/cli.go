@@ -2,9 +2,8 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net/http"
+	"io/fs"
 	"os"
 	"strconv"
 	"strings"
@@ -14,8 +13,8 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 	"github.com/varunamachi/libx"
+	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
-	"github.com/varunamachi/libx/httpx"
 	"github.com/varunamachi/libx/proc"
 )
 
@@ -60,28 +59,39 @@ func serveCmd(gtx context.Context) *cli.Command {
 		Name:        "serve",
 		Usage:       "Start the process manager server",
 		Description: "Start the process manager server",
-		Flags:       withServerFlags(),
+		Flags: withServerFlags(
+			&cli.StringFlag{
+				Name:  "socket-mode",
+				Usage: "file mode (octal) for the unix domain socket",
+				Value: "0600",
+			},
+			&cli.BoolFlag{
+				Name: "require-token",
+				Usage: "generate an access token and require it for all the " +
+					"requests, the token is stored in user's config dir",
+				Value:   false,
+				EnvVars: []string{"EXMAN_REQUIRE_TOKEN"},
+			},
+		),
 		Action: func(ctx *cli.Context) error {
-			server := Server{
-				server: httpx.NewServer(os.Stdout, nil),
-				man:    proc.NewManager(gtx),
+			mode, err := strconv.ParseUint(ctx.String("socket-mode"), 8, 32)
+			if err != nil {
+				return errx.Errf(err, "invalid socket mode '%s'",
+					ctx.String("socket-mode"))
 			}
 
-			// go func() {
-			// 	<-gtx.Done()
-			// 	fmt.Println("\nstopping server gracefully")
-			// 	if err := server.server.Close(); err != nil {
-			// 		log.Error().Err(err).Msg("failed to stop exec-server")
-			// 	}
-
-			// }()
-
-			err := server.Start(gtx, "127.0.0.1", uint32(ctx.Uint("port")))
-
-			if err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return err
+			server := Server{
+				man: proc.NewManager(gtx).
+					WithStats(proc.DefaultStatsInterval, 0),
 			}
-			return nil
+
+			return server.Start(gtx, &ServerOpts{
+				BindIP:       "127.0.0.1",
+				Port:         uint32(ctx.Uint("port")),
+				Socket:       ctx.String("socket"),
+				SocketMode:   fs.FileMode(mode),
+				RequireToken: ctx.Bool("require-token"),
+			})
 		},
 	}
 }
@@ -181,15 +191,34 @@ func listCmd() *cli.Command {
 				StyleFunc(func(row, col int) lipgloss.Style {
 					return lipgloss.NewStyle().Padding(0, 2)
 				}).
-				Headers("NAME", "PID", "STARTED_AT")
+				Headers(
+					"NAME",
+					"PID",
+					"STARTED_AT",
+					"CPU%",
+					"RSS",
+					"FDS",
+					"THREADS",
+					"CHILDREN",
+				)
 
 			rows := [][]string{}
 			for _, ci := range list {
-				rows = append(rows, []string{
+				row := []string{
 					ci.Desc.Name,
 					strconv.Itoa(ci.PID),
 					ci.Started.Format("2006 Jan 02 15:04:05"),
-				})
+					"-", "-", "-", "-", "-",
+				}
+				if st := ci.Stats; st != nil {
+					row[3] = strconv.FormatFloat(st.CPUPercent, 'f', 1, 64)
+					row[4] = humanBytes(st.RSS)
+					row[5] = data.Qop(
+						st.OpenFDs < 0, "-", strconv.Itoa(st.OpenFDs))
+					row[6] = strconv.Itoa(st.Threads)
+					row[7] = strconv.Itoa(st.Children)
+				}
+				rows = append(rows, row)
 			}
 			t.Rows(rows...)
 
@@ -252,15 +281,88 @@ func stopAllCmd() *cli.Command {
 	}
 }
 
+func statsCmd() *cli.Command {
+	return &cli.Command{
+		Name:        "stats",
+		Usage:       "Show resource usage history of a command",
+		Description: "Show resource usage history of a command",
+		Flags: withServerFlags(
+			&cli.StringFlag{
+				Name:     "name",
+				Usage:    "name of the command instance",
+				Required: false,
+			},
+		),
+		Action: func(ctx *cli.Context) error {
+			name := ctx.String("name")
+			if name == "" {
+				name = ctx.Args().First()
+			}
+			stats, err := client(ctx).Stats(ctx.Context, name)
+			if err != nil {
+				return err
+			}
+
+			t := table.New().
+				Border(lipgloss.NormalBorder()).
+				BorderStyle(lipgloss.NewStyle().
+					Foreground(lipgloss.Color("99")),
+				).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("TIME", "CPU%", "RSS", "FDS", "THREADS", "CHILDREN")
+
+			rows := [][]string{}
+			for _, st := range stats.History {
+				rows = append(rows, []string{
+					st.Time.Format("15:04:05"),
+					strconv.FormatFloat(st.CPUPercent, 'f', 1, 64),
+					humanBytes(st.RSS),
+					data.Qop(st.OpenFDs < 0, "-", strconv.Itoa(st.OpenFDs)),
+					strconv.Itoa(st.Threads),
+					strconv.Itoa(st.Children),
+				})
+			}
+			t.Rows(rows...)
+
+			fmt.Printf("%s (PID: %d)\n", stats.Name, stats.PID)
+			fmt.Println(t)
+			return nil
+		},
+	}
+}
+
+func humanBytes(val int64) string {
+	const unit = 1024
+	if val < unit {
+		return strconv.FormatInt(val, 10) + "B"
+	}
+	div, exp := int64(unit), 0
+	for n := val / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(val)/float64(div), "KMGTPE"[exp])
+}
+
 func withServerFlags(flags ...cli.Flag) []cli.Flag {
-	flags = append(flags, &cli.UintFlag{
-		Name:  "port",
-		Value: 12012,
-		Usage: "port number at which exec server is running",
-	})
+	flags = append(flags,
+		&cli.UintFlag{
+			Name:  "port",
+			Value: 12012,
+			Usage: "port number at which exec server is running",
+		},
+		&cli.StringFlag{
+			Name: "socket",
+			Usage: "path of the unix domain socket on which exec server is " +
+				"listening, takes precedence over port",
+			EnvVars: []string{"EXMAN_SOCKET"},
+		},
+	)
 	return flags
 }
 
 func client(ctx *cli.Context) *Client {
-	return NewClient(uint32(ctx.Uint("port")))
+	return NewClient(uint32(ctx.Uint("port")), ctx.String("socket"))
 }
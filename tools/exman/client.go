@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/varunamachi/libx/errx"
 	"github.com/varunamachi/libx/httpx"
@@ -13,9 +15,30 @@ type Client struct {
 	client *httpx.Client
 }
 
-func NewClient(port uint32) *Client {
-	url := fmt.Sprintf("http://127.0.0.1:%d", port)
-	client := httpx.NewClient(url, "")
+// NewClient - creates a client for exman server. If socket is not empty the
+// server is reached through the unix domain socket, otherwise through the
+// given port on localhost. The token stored by the server is used if present
+func NewClient(port uint32, socket string) *Client {
+	var client *httpx.Client
+	if socket != "" {
+		tp := httpx.DefaultTransport()
+		tp.Dial = nil
+		tp.DialContext = func(
+			gtx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(gtx, "unix", socket)
+		}
+		// Host part is ignored by the dialer
+		client = httpx.NewCustomClient(
+			"http://exman", "", tp, 20*time.Second)
+	} else {
+		url := fmt.Sprintf("http://127.0.0.1:%d", port)
+		client = httpx.NewClient(url, "")
+	}
+
+	if token := readToken(); token != "" {
+		client.SetToken(token)
+	}
 	return &Client{client: client}
 }
 
@@ -61,3 +84,13 @@ func (c *Client) TerminateAll(
 	}
 	return nil
 }
+
+func (c *Client) Stats(
+	gtx context.Context, name string) (*proc.CmdStats, error) {
+	res := c.client.Build().Path("/api/v1/cmd", name, "stats").Get(gtx)
+	var stats proc.CmdStats
+	if err := res.LoadClose(&stats); err != nil {
+		return nil, errx.Errf(err, "failed to get stats for cmd '%s'", name)
+	}
+	return &stats, nil
+}
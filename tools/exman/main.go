@@ -27,6 +27,7 @@ func main() {
 			listCmd(),
 			stopCmd(),
 			stopAllCmd(),
+			statsCmd(),
 			infoCmd(),
 		)
 
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"net/http"
 	"os"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/varunamachi/libx/data"
 	"github.com/varunamachi/libx/errx"
 	"github.com/varunamachi/libx/httpx"
+	"github.com/varunamachi/libx/netx"
 	"github.com/varunamachi/libx/proc"
 )
 
@@ -17,20 +20,47 @@ type Server struct {
 	man    *proc.Manager
 }
 
-func (s *Server) Start(gtx context.Context, bindIp string, port uint32) error {
+type ServerOpts struct {
+	BindIP       string
+	Port         uint32
+	Socket       string
+	SocketMode   fs.FileMode
+	RequireToken bool
+}
+
+func (s *Server) Start(gtx context.Context, opts *ServerOpts) error {
 	s.server = httpx.NewServer(os.Stdout, nil)
 
+	if opts.RequireToken {
+		token, err := createToken()
+		if err != nil {
+			return err
+		}
+		defer removeToken()
+		s.server.WithRootMiddlewares(tokenMiddleware(token))
+	}
+
 	s.server.WithAPIs(
 		s.executeEp(),
 		s.terminateEp(),
 		s.listEp(),
 		s.terminateAllEp(),
+		s.statsEp(),
 	)
 
-	if err := s.server.StartContext(gtx, port); err != nil {
-		if err != http.ErrServerClosed {
-			return errx.Wrap(err)
+	var err error
+	if opts.Socket != "" {
+		listener, lerr := netx.ListenUnix(opts.Socket, opts.SocketMode)
+		if lerr != nil {
+			return lerr
 		}
+		err = s.server.ServeContext(gtx, listener)
+	} else {
+		err = s.server.SetBindIP(opts.BindIP).StartContext(gtx, opts.Port)
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errx.Wrap(err)
 	}
 	return nil
 }
@@ -123,3 +153,30 @@ func (s *Server) listEp() *httpx.Endpoint {
 		Handler:  handler,
 	}
 }
+
+func (s *Server) statsEp() *httpx.Endpoint {
+	handler := func(etx echo.Context) error {
+		name := httpx.NewParamGetter(etx).Str("name")
+		stats, err := s.man.Stats(name)
+		if err != nil {
+			if errors.Is(err, proc.ErrCommandNotFound) {
+				return &echo.HTTPError{
+					Code:     http.StatusNotFound,
+					Message:  "command '" + name + "' not found",
+					Internal: err,
+				}
+			}
+			return err
+		}
+		return httpx.SendJSON(etx, stats)
+	}
+
+	return &httpx.Endpoint{
+		Method:   echo.GET,
+		Path:     "cmd/:name/stats",
+		Category: "cmd-exec",
+		Desc:     "Get resource usage statistics of a managed command",
+		Version:  "v1",
+		Handler:  handler,
+	}
+}
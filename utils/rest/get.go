@@ -10,112 +10,196 @@ import (
 	"github.com/varunamachi/libx/httpx"
 )
 
-func GetCommonParams(etx echo.Context) (*data.CommonParams, error) {
+// MaxPageSize - page sizes given in requests are capped to this
+var MaxPageSize int64 = 1000
+
+// GetCommonParams - reads common params from query params. The 'sort' param
+// is either a single field with 'sortDesc' giving the direction or sort
+// fields in the form accepted by data.ParseSorts. Filter is read as given by
+// GetFilter. Negative page or page size is a bad request, page size is capped
+// to MaxPageSize
+func GetCommonParams(
+	etx echo.Context,
+	specs ...*data.FilterSpec) (*data.CommonParams, error) {
 	pmg := httpx.NewParamGetter(etx)
 
 	page := pmg.QueryInt64Or("page", 0)
 	pageSize := pmg.QueryInt64Or("pageSize", 0)
 	sort := pmg.QueryStrOr("sort", "")
 	sortDesc := pmg.QueryBoolOr("sortDesc", false)
+	cursor := pmg.QueryStrOr("cursor", "")
 
-	var filter data.Filter
-	pmg.QueryJSON("filter", &filter)
+	filter, err := readFilter(etx, pmg, specs)
+	if err != nil {
+		return nil, err
+	}
 
 	if pmg.HasError() {
 		pmg.WriteDetailedError(os.Stdout)
 		return nil, pmg.BadReqError()
 	}
-	return &data.CommonParams{
-		Page:           page,
-		PageSize:       pageSize,
-		Sort:           sort,
-		SortDescending: sortDesc,
-		Filter:         &filter,
-	}, nil
+	if page < 0 || pageSize < 0 {
+		return nil, errx.BadReq(
+			"page and pageSize should not be negative, got %d and %d",
+			page, pageSize)
+	}
+	pageSize = min(pageSize, MaxPageSize)
+
+	sorts, err := data.ParseSorts(sort)
+	if err != nil {
+		return nil, err
+	}
+	cparams := &data.CommonParams{
+		Page:     page,
+		PageSize: pageSize,
+		Filter:   filter,
+		Cursor:   cursor,
+	}
+	if len(sorts) == 1 && sorts[0].Nulls == data.NullsDefault {
+		cparams.Sort = sorts[0].Field
+		cparams.SortDescending = sorts[0].Desc || sortDesc
+	} else {
+		cparams.Sorts = sorts
+	}
+	return cparams, nil
 }
 
-func GetFilter(etx echo.Context) (*data.Filter, error) {
+// GetFilter - reads filter from the 'q' query param written in the filter
+// query language, see data.ParseFilter. If there is no 'q' param, filter is
+// read from the 'filter' param as JSON. If specs are given, only the fields
+// from the specs are allowed in the query
+func GetFilter(
+	etx echo.Context, specs ...*data.FilterSpec) (*data.Filter, error) {
 	pmg := httpx.NewParamGetter(etx)
-	var filter data.Filter
-	pmg.QueryJSON("filter", &filter)
+	filter, err := readFilter(etx, pmg, specs)
+	if err != nil {
+		return nil, err
+	}
 
 	if pmg.HasError() {
 		pmg.WriteDetailedError(os.Stdout)
 		return nil, pmg.Error()
 	}
-	return &filter, nil
+	return filter, nil
 }
 
-func Get[T any](etx echo.Context, gdr data.GetterDeleter) ([]T, error) {
-	dtype := etx.Param("dtype")
-	if dtype == "" {
-		return nil, errx.BadReq("data type not given")
+func readFilter(
+	etx echo.Context,
+	pmg *httpx.ParamGetter,
+	specs []*data.FilterSpec) (*data.Filter, error) {
+	if etx.QueryParams().Has("q") {
+		return data.ParseFilter(etx.QueryParam("q"), specs...)
 	}
+	var filter data.Filter
+	pmg.QueryJSON("filter", &filter)
+	return &filter, nil
+}
 
+// Get - lists items from the repository based on common params in the
+// request
+func Get[T any](etx echo.Context, repo data.Repository[T]) ([]T, error) {
 	cparams, err := GetCommonParams(etx)
 	if err != nil {
 		return nil, errx.BadReqX(err,
-			"failed to get common parameters to get '%s'", dtype)
+			"failed to get common parameters to get '%s'", repo.Info().Name)
 	}
+	return repo.List(etx.Request().Context(), cparams)
+}
 
-	out := make([]T, 0, 100)
-
-	err = gdr.Get(etx.Request().Context(), dtype, cparams, []any{})
+// GetPage - gets a page of items from the repository along with cursors for
+// adjacent pages. Keyset pagination is used if the request has a cursor
+func GetPage[T any](
+	etx echo.Context, repo data.Repository[T]) (*data.Page[T], error) {
+	cparams, err := GetCommonParams(etx)
 	if err != nil {
-		return nil, err
+		return nil, errx.BadReqX(err,
+			"failed to get common parameters to get '%s'", repo.Info().Name)
 	}
-	return out, nil
+	return repo.ListPage(etx.Request().Context(), cparams)
 }
 
-func Count(etx echo.Context, gdr data.GetterDeleter) (int64, error) {
-	dtype := etx.Param("dtype")
-	if dtype == "" {
-		return 0, errx.BadReq("data type not given")
-	}
-
+// Count - counts items in the repository that match the filter in the
+// request
+func Count[T any](etx echo.Context, repo data.Repository[T]) (int64, error) {
 	filter, err := GetFilter(etx)
 	if err != nil {
 		return 0, errx.BadReqX(err,
-			"failed to get filter to count in '%s'", dtype)
+			"failed to get filter to count in '%s'", repo.Info().Name)
 	}
+	return repo.Count(etx.Request().Context(), filter)
+}
 
-	return gdr.Count(etx.Request().Context(), dtype, filter)
+// GetByKey - gets the item whose key is given in the named path param
+func GetByKey[T any](
+	etx echo.Context, repo data.Repository[T], param string) (*T, error) {
+	key, err := repo.Info().ParseKey(etx.Param(param))
+	if err != nil || etx.Param(param) == "" {
+		return nil, errx.BadReq("invalid or no key given in '%s'", param)
+	}
+	return repo.Get(etx.Request().Context(), key)
 }
 
-func GetX[T any](etx echo.Context, tableQuery string) ([]T, error) {
+// Delete - deletes the item whose key is given in the named path param
+func Delete[T any](
+	etx echo.Context, repo data.Repository[T], param string) error {
+	key, err := repo.Info().ParseKey(etx.Param(param))
+	if err != nil || etx.Param(param) == "" {
+		return errx.BadReq("invalid or no key given in '%s'", param)
+	}
+	return repo.Delete(etx.Request().Context(), key)
+}
 
-	cparams, err := GetCommonParams(etx)
+// GetX - gets data using given query, filter and sort fields are allowed only
+// if they are part of given specs. If no specs are given, requests with
+// filter or sort fields are rejected
+func GetX[T any](
+	etx echo.Context,
+	tableQuery string,
+	specs ...*data.FilterSpec) ([]T, error) {
+
+	cparams, err := GetCommonParams(etx, specs...)
 	if err != nil {
 		return nil, errx.BadReqX(err,
 			"failed to get common parameters to get data")
 	}
 
-	sel := pg.NewSelectorGenerator().SelectorX(cparams)
-	query := tableQuery + " WHERE " + sel.QueryFragment
+	sel, err := pg.NewSelectorGenerator(specs...).SelectorX(cparams)
+	if err != nil {
+		return nil, err
+	}
+	query := tableQuery + sel.Clause()
 
 	out := make([]T, 0, 100)
 
-	err = pg.Conn().SelectContext(
-		etx.Request().Context(), &out, query, sel.Args...)
+	gtx := etx.Request().Context()
+	err = pg.ConnFor(gtx, nil).SelectContext(gtx, &out, query, sel.Args...)
 	if err != nil {
 		return nil, errx.Errf(err, "failed to get data")
 	}
 	return out, nil
 }
 
-func GetOne[T any](etx echo.Context, tableQuery string) (T, error) {
-	filter, err := GetFilter(etx)
+// GetOne - gets a single item using given query, filter fields are checked
+// against the specs like in GetX
+func GetOne[T any](
+	etx echo.Context,
+	tableQuery string,
+	specs ...*data.FilterSpec) (T, error) {
+	filter, err := GetFilter(etx, specs...)
 	var out T
 	if err != nil {
 		return out, errx.BadReqX(err,
 			"failed to get filter to count")
 	}
 
-	sel := pg.NewSelectorGenerator().Selector(filter)
-	query := tableQuery + " WHERE " + sel.QueryFragment
+	sel, err := pg.NewSelectorGenerator(specs...).Selector(filter)
+	if err != nil {
+		return out, err
+	}
+	query := tableQuery + sel.Clause()
 
-	err = pg.Conn().GetContext(
-		etx.Request().Context(), &out, query, sel.Args...)
+	gtx := etx.Request().Context()
+	err = pg.ConnFor(gtx, nil).GetContext(gtx, &out, query, sel.Args...)
 	if err != nil {
 		return out, errx.Errf(err, "failed to get data")
 	}